@@ -0,0 +1,71 @@
+package main
+
+import (
+	"linkshortener/lib/blocklist"
+	"linkshortener/model"
+	"linkshortener/setting"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+)
+
+func TestBlocklist(t *testing.T) {
+	previous := setting.Cfg.Blocklist
+	defer func() { setting.Cfg.Blocklist = previous }()
+
+	dir := t.TempDir()
+	feeds := map[string]string{
+		"urlhaus.csv": "# id,dateadded,url,url_status,last_online,threat,tags,urlhaus_link,reporter\n" +
+			`"1","2024-03-01 10:00:00","http://malware.example/payload.exe","online","","malware_download","exe","https://urlhaus.abuse.ch/url/1/","reporter"` + "\n",
+		"phishtank.csv": "phish_id,url,phish_detail_url,submission_time,verified,verification_time,online,target\n" +
+			"2,https://bank.example.net/login/*,https://phishtank.org/phish_detail.php?phish_id=2,,yes,,yes,Other\n",
+		"domains.txt": "# plain domains\nPhishing.example\n0.0.0.0 tracker.example\n",
+	}
+	for name, content := range feeds {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	setting.Cfg.Blocklist = model.BlocklistConfig{EnableBlocklist: true, FeedDir: dir}
+
+	size, err := blocklist.Reload()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, size, 4)
+
+	listed := func(rawURL string) bool {
+		_, ok := blocklist.CheckURL(rawURL)
+		return ok
+	}
+
+	t.Run("URL Feed", func(t *testing.T) {
+		assert.Equal(t, listed("https://malware.example/payload.exe"), true)
+		assert.Equal(t, listed("http://MALWARE.example:8080/payload.exe#x"), true)
+		assert.Equal(t, listed("http://malware.example/other.exe"), false)
+		assert.Equal(t, listed("https://bank.example.net/login/verify?id=1"), true)
+		assert.Equal(t, listed("https://bank.example.net/about"), false)
+	})
+
+	t.Run("Domain Feed", func(t *testing.T) {
+		assert.Equal(t, listed("https://phishing.example/"), true)
+		assert.Equal(t, listed("https://secure.login.phishing.example/a"), true)
+		assert.Equal(t, listed("https://tracker.example/pixel.gif"), true)
+		assert.Equal(t, listed("https://example.com/phishing.example"), false)
+
+		reason, _ := blocklist.CheckURL("https://phishing.example/")
+		assert.Equal(t, reason, "host phishing.example is listed by domains.txt")
+	})
+
+	t.Run("Link Destinations", func(t *testing.T) {
+		link := model.Link{
+			URL:      "https://example.com/",
+			Variants: []model.Variant{{Name: "a", URL: "https://example.com/a"}, {Name: "b", URL: "https://phishing.example/b"}},
+		}
+		_, ok := blocklist.CheckLink(link)
+		assert.Equal(t, ok, true)
+		link.Variants = link.Variants[:1]
+		_, ok = blocklist.CheckLink(link)
+		assert.Equal(t, ok, false)
+	})
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"linkshortener/db"
+	"linkshortener/lib/bulk"
+	"linkshortener/lib/passhash"
+	"linkshortener/lib/urlpolicy"
+	"linkshortener/model"
+	"linkshortener/setting"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/go-playground/assert/v2"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestBulk(t *testing.T) {
+	t.Run("ParseJSON", func(t *testing.T) {
+		items, err := bulk.ParseJSON(strings.NewReader(`[{"link":"https://example.com/a","memo":"a"},{"link":"https://example.com/b","alias":"spring24","expire":1893456000}]`), 2)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, len(items), 2)
+		assert.Equal(t, items[1], model.BulkLinkItem{URL: "https://example.com/b", EXPIRE: 1893456000, Alias: "spring24"})
+
+		_, err = bulk.ParseJSON(strings.NewReader(`[{"link":"a"},{"link":"b"},{"link":"c"}]`), 2)
+		assert.Equal(t, err, bulk.ErrTooLarge)
+		_, err = bulk.ParseJSON(strings.NewReader(`[]`), 2)
+		assert.Equal(t, err, bulk.ErrEmpty)
+		_, err = bulk.ParseJSON(strings.NewReader(`{"link":"a"}`), 2)
+		assert.NotEqual(t, err, nil)
+	})
+
+	t.Run("ParseCSV", func(t *testing.T) {
+		document := "\xEF\xBB\xBFalias, Link,expire\nspring24,https://example.com/a,1893456000\n,https://example.com/b,\n"
+		items, err := bulk.ParseCSV(strings.NewReader(document), 10)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, items, []model.BulkLinkItem{
+			{URL: "https://example.com/a", EXPIRE: 1893456000, Alias: "spring24"},
+			{URL: "https://example.com/b"},
+		})
+
+		_, err = bulk.ParseCSV(strings.NewReader("memo\nhello\n"), 10)
+		assert.NotEqual(t, err, nil)
+		_, err = bulk.ParseCSV(strings.NewReader("link,owner\nhttps://example.com/,me\n"), 10)
+		assert.NotEqual(t, err, nil)
+		_, err = bulk.ParseCSV(strings.NewReader("link,expire\nhttps://example.com/,tomorrow\n"), 10)
+		assert.Equal(t, err.Error(), "row 2: expire is not a number")
+		_, err = bulk.ParseCSV(strings.NewReader("link\n"), 10)
+		assert.Equal(t, err, bulk.ErrEmpty)
+		_, err = bulk.ParseCSV(strings.NewReader("link\na\nb\nc\n"), 2)
+		assert.Equal(t, err, bulk.ErrTooLarge)
+	})
+
+	t.Run("RequestHash", func(t *testing.T) {
+		a := []model.BulkLinkItem{{URL: "https://example.com/a"}}
+		b := []model.BulkLinkItem{{URL: "https://example.com/b"}}
+		assert.Equal(t, bulk.RequestHash(a), bulk.RequestHash([]model.BulkLinkItem{{URL: "https://example.com/a"}}))
+		assert.NotEqual(t, bulk.RequestHash(a), bulk.RequestHash(b))
+	})
+
+	t.Run("Each", func(t *testing.T) {
+		var inFlight, maxInFlight atomic.Int64
+		seen := make([]int, 50)
+		var mu sync.Mutex
+		bulk.Each(len(seen), 4, func(i int) {
+			current := inFlight.Add(1)
+			defer inFlight.Add(-1)
+			for {
+				peak := maxInFlight.Load()
+				if current <= peak || maxInFlight.CompareAndSwap(peak, current) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			mu.Lock()
+			seen[i]++
+			mu.Unlock()
+		})
+		for _, count := range seen {
+			assert.Equal(t, count, 1)
+		}
+		assert.Equal(t, maxInFlight.Load() <= 4, true)
+	})
+}
+
+func TestInsertFailIfExistsBadger(t *testing.T) {
+	badgerDB, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer badgerDB.Close()
+
+	var lls db.LlsBadgerDB
+	table := db.NewBadgerDBTable(lls.SetBadgerDB(badgerDB), "idempotency_keys")
+	record := model.IdempotencyRecord{ID: "key", RequestHash: "first"}
+	_, err = table.InsertOne(record, false, db.Insert().SetTTL(time.Hour).SetFailIfExists(true))
+	assert.Equal(t, err, nil)
+
+	record.RequestHash = "second"
+	_, err = table.InsertOne(record, false, db.Insert().SetFailIfExists(true))
+	assert.Equal(t, errors.Is(err, db.ErrDuplicateKey), true)
+
+	// Concurrent inserts of the same key, only one of them wins
+	var inserted atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := table.InsertOne(model.IdempotencyRecord{ID: "race"}, false, db.Insert().SetFailIfExists(true)); err == nil {
+				inserted.Add(1)
+			} else if !errors.Is(err, db.ErrDuplicateKey) {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, inserted.Load(), int64(1))
+
+	// Updates keep the expiry of the document
+	err = table.UpdateByID("key", bson.M{"$set": bson.M{"done": true, "response": "{}"}})
+	assert.Equal(t, err, nil)
+	var stored model.IdempotencyRecord
+	assert.Equal(t, table.FindByID("key", &stored), nil)
+	assert.Equal(t, stored.RequestHash, "first")
+	assert.Equal(t, stored.Done, true)
+	_ = badgerDB.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte("idempotency_keys:key"))
+		assert.Equal(t, err, nil)
+		assert.NotEqual(t, item.ExpiresAt(), uint64(0))
+		return nil
+	})
+}
+
+func TestIdempotencyClaim(t *testing.T) {
+	useBadgerDB(t)
+
+	now := time.Now()
+	_, claimed, err := db.ClaimIdempotencyKey("key", "first", now)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, claimed, true)
+
+	// A retry while the first request runs sees the pending claim
+	record, claimed, err := db.ClaimIdempotencyKey("key", "first", now.Add(time.Second))
+	assert.Equal(t, err, nil)
+	assert.Equal(t, claimed, false)
+	assert.Equal(t, record.Done, false)
+
+	// A failed request releases its claim, the retry runs again
+	assert.Equal(t, db.ReleaseIdempotencyKey("key"), nil)
+	_, claimed, err = db.ClaimIdempotencyKey("key", "first", now.Add(2*time.Second))
+	assert.Equal(t, err, nil)
+	assert.Equal(t, claimed, true)
+
+	// A claim past its lease is taken over by exactly one of the retries
+	later := now.Add(db.IdempotencyLease + time.Minute)
+	var taken atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, claimed, err := db.ClaimIdempotencyKey("key", "first", later); err != nil {
+				t.Error(err)
+			} else if claimed {
+				taken.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, taken.Load(), int64(1))
+
+	// A completed key is replayed however old it is, and is not released
+	assert.Equal(t, db.CompleteIdempotencyKey("key", "{}"), nil)
+	assert.Equal(t, db.ReleaseIdempotencyKey("key"), nil)
+	record, claimed, err = db.ClaimIdempotencyKey("key", "first", later.Add(db.IdempotencyLease+time.Minute))
+	assert.Equal(t, err, nil)
+	assert.Equal(t, claimed, false)
+	assert.Equal(t, record.Done, true)
+	assert.Equal(t, record.Response, "{}")
+}
+
+func TestBulkReplayTokens(t *testing.T) {
+	router := useRouter(t)
+	passhash.InitPasshash("test-key")
+	previous := setting.Cfg.Destination
+	defer func() {
+		setting.Cfg.Destination = previous
+		urlpolicy.InitPolicy()
+	}()
+	setting.Cfg.Destination = model.DestinationConfig{SkipResolve: true}
+	urlpolicy.InitPolicy()
+	setting.Cfg.Bulk.APIKeys = []string{"test-api-key"}
+
+	post := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/bulk_generate_link", strings.NewReader(`[{"link":"https://example.com/a","alias":"replay1"},{"link":"https://example.com/b","alias":"replay2"}]`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer test-api-key")
+		req.Header.Set("Idempotency-Key", "retry-after-timeout")
+		return serve(router, req)
+	}
+	generate := func() (http.Header, []model.BulkLinkResult) {
+		response := post()
+		assert.Equal(t, response.Code, http.StatusOK)
+		var result struct {
+			Data struct {
+				Results []model.BulkLinkResult `json:"results"`
+			} `json:"data"`
+		}
+		assert.Equal(t, json.Unmarshal(response.Body.Bytes(), &result), nil)
+		return response.Header(), result.Data.Results
+	}
+
+	_, first := generate()
+	assert.Equal(t, len(first), 2)
+	for _, result := range first {
+		assert.Equal(t, result.Success, true)
+		assert.NotEqual(t, result.Token, "")
+	}
+
+	// A retry after a lost response gets the same links with their manage tokens
+	header, replayed := generate()
+	assert.Equal(t, header.Get("Idempotent-Replayed"), "true")
+	assert.Equal(t, replayed, first)
+
+	// The stored response does not hold the tokens in plain text
+	var record model.IdempotencyRecord
+	assert.Equal(t, db.SetModel(setting.Cfg.DB.Database, "idempotency_keys").FindEach(bson.M{}, db.Find(), func(decode func(result interface{}) error) error {
+		return decode(&record)
+	}), nil)
+	assert.Equal(t, record.Done, true)
+	assert.Equal(t, strings.Contains(record.Response, first[0].Token), false)
+
+	// Tokens sealed under another key are refused instead of replayed empty
+	passhash.InitPasshash("rotated-key")
+	assert.Equal(t, post().Code, http.StatusInternalServerError)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"linkshortener/db"
+	"linkshortener/model"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/go-playground/assert/v2"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestUpdateByIDIfBadger(t *testing.T) {
+	badgerDB, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer badgerDB.Close()
+
+	var lls db.LlsBadgerDB
+	table := db.NewBadgerDBTable(lls.SetBadgerDB(badgerDB), "links")
+	_, err = table.InsertOne(model.Link{ShortHash: "limited", MaxClicks: 5}, false)
+	assert.Equal(t, err, nil)
+
+	var consumed atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ok, err := table.UpdateByIDIf("limited",
+				bson.D{{Key: "clicks", Value: bson.M{"$lt": 5}}},
+				bson.M{"$inc": bson.M{"clicks": 1}},
+			)
+			if err != nil {
+				t.Error(err)
+			}
+			if ok {
+				consumed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, consumed.Load(), int64(5))
+
+	var res []model.Link
+	_ = table.Find(bson.D{{Key: "_id", Value: "limited"}}, &res, db.Find().SetKey("limited"))
+	assert.Equal(t, len(res), 1)
+	assert.Equal(t, res[0].Clicks, int64(5))
+}
+
+func TestUpdateByIDBadger(t *testing.T) {
+	badgerDB, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer badgerDB.Close()
+
+	var lls db.LlsBadgerDB
+	table := db.NewBadgerDBTable(lls.SetBadgerDB(badgerDB), "links")
+	// A link stored by an older version without the newer fields
+	type olderLink struct {
+		ShortHash string `bson:"_id"`
+		URL       string `bson:"url"`
+	}
+	_, err = table.InsertOne(olderLink{ShortHash: "older", URL: "https://example.com/"}, false)
+	assert.Equal(t, err, nil)
+
+	// $set adds the fields the link does not have yet
+	err = table.UpdateByID("older", bson.M{"$set": bson.M{"geo_fallback": "https://example.com/fallback"}})
+	assert.Equal(t, err, nil)
+	var link model.Link
+	assert.Equal(t, table.FindByID("older", &link), nil)
+	assert.Equal(t, link.URL, "https://example.com/")
+	assert.Equal(t, link.GeoFallback, "https://example.com/fallback")
+
+	// A deleted link is not brought back
+	assert.NotEqual(t, table.UpdateByID("deleted", bson.M{"$set": bson.M{"geo_fallback": "https://example.com/"}}), nil)
+	assert.NotEqual(t, table.FindByID("deleted", &link), nil)
+}
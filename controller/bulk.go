@@ -0,0 +1,284 @@
+package controller
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"linkshortener/db"
+	"linkshortener/i18n"
+	"linkshortener/lib/bulk"
+	"linkshortener/lib/passhash"
+	"linkshortener/lib/tool"
+	"linkshortener/log"
+	"linkshortener/model"
+	"linkshortener/setting"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+const (
+	defaultBulkMaxBatchSize = 500
+	defaultBulkConcurrency  = 8
+	bulkItemMaxBytes        = 4096 // Upper bound of the body size per link
+	idempotencyKeyMaxLength = 128
+)
+
+// reservedAliases Hashes that already have a meaning in Redirect and Ping
+var reservedAliases = map[string]struct{}{"ping": {}, "000000": {}}
+
+// BulkGenerateLinks This method creates many links at once for API clients authenticated with an API key
+// Usage:
+// Send http POST call to
+// {BasePath}/api/bulk_generate_link
+// with "Authorization: Bearer {API key}" and a JSON array, a text/csv body or a multipart form with a CSV "file".
+// Retries sending the same "Idempotency-Key" header get the response of the first request instead of new links.
+func BulkGenerateLinks(c *gin.Context) {
+	localizer := i18n.GetLocalizer(c)
+
+	keyID, lockedUntil, ok := checkAPIKey(c, strings.TrimSpace(strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")))
+	if !ok {
+		if lockedUntil > 0 {
+			tooManyAttemptsResponse(c, localizer, lockedUntil)
+			return
+		}
+		c.Header("WWW-Authenticate", "Bearer")
+		model.FailureResponse(c, http.StatusUnauthorized, http.StatusUnauthorized, localizer.GetMessage("invalidAPIKey", nil), "")
+		return
+	}
+
+	maxBatchSize := setting.Cfg.Bulk.MaxBatchSize
+	if maxBatchSize <= 0 {
+		maxBatchSize = defaultBulkMaxBatchSize
+	}
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, int64(maxBatchSize)*bulkItemMaxBytes)
+
+	items, err := parseBulkBody(c, maxBatchSize)
+	var maxBytesErr *http.MaxBytesError
+	if errors.Is(err, bulk.ErrTooLarge) || errors.As(err, &maxBytesErr) {
+		model.FailureResponse(c, http.StatusRequestEntityTooLarge, http.StatusRequestEntityTooLarge, localizer.GetMessage("bulkBatchTooLarge", map[string]interface{}{"Max": maxBatchSize}), strconv.Itoa(maxBatchSize))
+		return
+	}
+	if err != nil {
+		model.FailureResponse(c, http.StatusBadRequest, http.StatusBadRequest, localizer.GetMessage("deserializationFailed", nil), err.Error())
+		log.ErrorPrint("Deserialization failed: %s", err)
+		return
+	}
+
+	var recordID string
+	completed := false
+	if idempotencyKey := c.GetHeader("Idempotency-Key"); idempotencyKey != "" {
+		if len(idempotencyKey) > idempotencyKeyMaxLength {
+			model.FailureResponse(c, http.StatusBadRequest, http.StatusBadRequest, localizer.GetMessage("invalidIdempotencyKey", nil), "")
+			return
+		}
+		// Keys are scoped to the API key, so clients cannot replay the responses of each other
+		sum := sha256.Sum256([]byte(idempotencyKey))
+		recordID = tool.ConcatStrings(keyID, ":", hex.EncodeToString(sum[:]))
+
+		record, claimed, err := db.ClaimIdempotencyKey(recordID, bulk.RequestHash(items), time.Now())
+		if err != nil {
+			model.FailureResponse(c, http.StatusInternalServerError, http.StatusInternalServerError, localizer.GetMessage("databaseOperationFailed", nil), "")
+			log.ErrorPrint("Claim idempotency key failed: %s", err)
+			return
+		}
+		if !claimed {
+			replayBulkResponse(c, localizer, record, bulk.RequestHash(items))
+			return
+		}
+		// Retries must not wait for the lease when this request ends without storing its response
+		defer func() {
+			if completed {
+				return
+			}
+			if err := db.ReleaseIdempotencyKey(recordID); err != nil {
+				log.ErrorPrint("Release idempotency key failed: %s", err)
+			}
+		}()
+	}
+
+	concurrency := setting.Cfg.Bulk.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultBulkConcurrency
+	}
+	now := time.Now().Unix()
+	results := make([]model.BulkLinkResult, len(items))
+	bulk.Each(len(items), concurrency, func(i int) {
+		results[i] = createBulkLink(localizer, i, items[i], now)
+	})
+
+	created := 0
+	for _, result := range results {
+		if result.Success {
+			created++
+		}
+	}
+	log.InfoPrint("Bulk creation of %d links by API key %s: %d created", len(items), keyID, created)
+
+	if recordID != "" {
+		response, err := replayableResponse(results, created)
+		if err == nil {
+			err = db.CompleteIdempotencyKey(recordID, response)
+		}
+		if err != nil {
+			log.ErrorPrint("Store idempotent response failed: %s", err)
+		} else {
+			completed = true
+		}
+	}
+
+	model.SuccessResponse(c, bulkResponse(results, created))
+}
+
+func bulkResponse(results []model.BulkLinkResult, created int) map[string]interface{} {
+	return map[string]interface{}{
+		"results": results,
+		"created": created,
+		"failed":  len(results) - created,
+	}
+}
+
+// replayableResponse The response stored for retries, the manage tokens are encrypted since only their hash is kept with the links
+func replayableResponse(results []model.BulkLinkResult, created int) (string, error) {
+	stored := make([]model.BulkLinkResult, len(results))
+	for i, result := range results {
+		if result.Token != "" {
+			var err error
+			if result.Token, err = passhash.EncryptToken(result.Token); err != nil {
+				return "", err
+			}
+		}
+		stored[i] = result
+	}
+	response, err := json.Marshal(bulkResponse(stored, created))
+	return string(response), err
+}
+
+// replayBulkResponse Answer a request whose Idempotency-Key was already used
+func replayBulkResponse(c *gin.Context, localizer i18n.ITranslator, record model.IdempotencyRecord, requestHash string) {
+	if record.RequestHash != requestHash {
+		model.FailureResponse(c, http.StatusUnprocessableEntity, http.StatusUnprocessableEntity, localizer.GetMessage("idempotencyKeyReused", nil), "")
+		return
+	}
+	if !record.Done {
+		model.FailureResponse(c, http.StatusConflict, http.StatusConflict, localizer.GetMessage("idempotencyKeyInProgress", nil), "")
+		return
+	}
+
+	var stored struct {
+		Results []model.BulkLinkResult `json:"results"`
+		Created int                    `json:"created"`
+	}
+	if err := json.Unmarshal([]byte(record.Response), &stored); err != nil {
+		model.FailureResponse(c, http.StatusInternalServerError, http.StatusInternalServerError, localizer.GetMessage("databaseOperationFailed", nil), "")
+		log.ErrorPrint("Read idempotent response failed: %s", err)
+		return
+	}
+	for i, result := range stored.Results {
+		if result.Token == "" {
+			continue
+		}
+		token, err := passhash.DecryptToken(result.Token)
+		if err != nil {
+			// The token hash key changed, a replay without the tokens would leave the links unmanageable
+			model.FailureResponse(c, http.StatusInternalServerError, http.StatusInternalServerError, localizer.GetMessage("databaseOperationFailed", nil), "")
+			log.ErrorPrint("Decrypt token of idempotent response failed: %s", err)
+			return
+		}
+		stored.Results[i].Token = token
+	}
+	c.Header("Idempotent-Replayed", "true")
+	model.SuccessResponse(c, bulkResponse(stored.Results, stored.Created))
+}
+
+// parseBulkBody Read the links from a JSON array, a CSV body or a CSV file uploaded as the "file" form field
+func parseBulkBody(c *gin.Context, maxBatchSize int) ([]model.BulkLinkItem, error) {
+	switch c.ContentType() {
+	case binding.MIMEJSON:
+		return bulk.ParseJSON(c.Request.Body, maxBatchSize)
+	case "text/csv":
+		return bulk.ParseCSV(c.Request.Body, maxBatchSize)
+	case binding.MIMEMultipartPOSTForm:
+		fileHeader, err := c.FormFile("file")
+		if err != nil {
+			return nil, err
+		}
+		file, err := fileHeader.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		return bulk.ParseCSV(file, maxBatchSize)
+	default:
+		return nil, errors.New("the body must be application/json, text/csv or multipart/form-data")
+	}
+}
+
+// createBulkLink Check and store one link, the result carries the error instead of failing the batch
+func createBulkLink(localizer i18n.ITranslator, index int, item model.BulkLinkItem, now int64) model.BulkLinkResult {
+	result := model.BulkLinkResult{Index: index}
+	if err := binding.Validator.ValidateStruct(item); err != nil {
+		result.Message = localizer.GetMessage("deserializationFailed", nil)
+		result.Detail = err.Error()
+		return result
+	}
+	if _, reserved := reservedAliases[strings.ToLower(item.Alias)]; reserved {
+		result.Message = localizer.GetMessage("aliasTaken", nil)
+		return result
+	}
+
+	req := model.InsertLinkReq{
+		URL:      item.URL,
+		PASSWORD: item.PASSWORD,
+		EXPIRE:   item.EXPIRE,
+		MEMO:     item.MEMO,
+	}
+	if linkErr := prepareLink(localizer, &req, now); linkErr != nil {
+		result.Message = linkErr.message
+		result.Detail = linkErr.detail
+		return result
+	}
+
+	link, token, err := saveLink(req, item.Alias)
+	if errors.Is(err, db.ErrDuplicateKey) {
+		result.Message = localizer.GetMessage("aliasTaken", nil)
+		return result
+	}
+	if err != nil {
+		result.Message = localizer.GetMessage("databaseOperationFailed", nil)
+		return result
+	}
+
+	result.Success = true
+	result.Hash = link.ShortHash
+	result.Token = token
+	return result
+}
+
+// checkAPIKey Verify the API key with brute-force protection per client IP.
+// keyID identifies the matching configured key without revealing it.
+func checkAPIKey(c *gin.Context, key string) (keyID string, lockedUntil int64, ok bool) {
+	now := time.Now()
+	subject := tool.ConcatStrings(lockoutKindAPIKey, ":ip:", c.ClientIP())
+	if lockedUntil = db.LockedUntil(now, subject); lockedUntil > 0 {
+		return "", lockedUntil, false
+	}
+
+	for _, stored := range setting.Cfg.Bulk.APIKeys {
+		stored = strings.TrimSpace(stored)
+		if passhash.VerifyToken(stored, key) {
+			sum := sha256.Sum256([]byte(stored))
+			keyID = hex.EncodeToString(sum[:8])
+		}
+	}
+	if keyID == "" {
+		return "", db.RecordFailure(now, subject), false
+	}
+	db.ResetFailures(subject)
+	return keyID, 0, true
+}
@@ -44,8 +44,8 @@ func DeleteLink(c *gin.Context) {
 	_ = table.Find(bson.D{{Key: "_id", Value: req.Hash}, {Key: "delete", Value: false}}, &res, db.Find().SetKey(req.Hash))
 
 	if res != nil && len(res) > 0 {
-		if res[0].Token != req.Token {
-			model.FailureResponse(c, http.StatusForbidden, http.StatusForbidden, localizer.GetMessage("passwordVerificationFailed", nil), "")
+		if lockedUntil, ok := checkManageToken(c, res[0], req.Token); !ok {
+			manageTokenFailure(c, localizer, lockedUntil)
 			return
 		}
 		err := table.UpdateByID(req.Hash, bson.M{
@@ -0,0 +1,110 @@
+package controller
+
+import (
+	"linkshortener/lib/schedule"
+	"linkshortener/lib/tool"
+	"linkshortener/model"
+	"linkshortener/setting"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	QueryPrecedenceIncoming = "incoming"
+	QueryPrecedenceTarget   = "target"
+)
+
+// controlQueryParams Query parameters consumed by LLS itself, they are never forwarded to the destination
+var controlQueryParams = []string{"pwd", "soft", "detect"}
+
+// resolvedDestination The outcome of resolveDestination
+type resolvedDestination struct {
+	URL      string
+	Fallback string // Web fallback when URL is an app deep link
+	Variant  string // Name of the variant served, empty when a targeting rule took over
+}
+
+// resolveDestination Work out the URL the visitor is sent to for this request.
+// A matching schedule rule or else a weighted variant replaces the link URL, the targeting rules take precedence
+// over it (device rules before geo rules), then the query and path are passed through.
+func resolveDestination(c *gin.Context, link model.Link, req model.RedirectLinkReq, location model.Location, uaInfo model.UAInfo, now time.Time) resolvedDestination {
+	var resolved resolvedDestination
+	if scheduled, ok := schedule.Destination(link.ScheduleRules, now); ok {
+		link.URL = scheduled
+	} else if variant, ok := chooseVariant(c, link); ok {
+		link.URL = variant.URL
+		resolved.Variant = variant.Name
+	}
+
+	resolved.URL = geoDestination(link, location)
+	if rule, ok := deviceDestination(link, uaInfo); ok {
+		resolved.URL, resolved.Fallback = rule.URL, rule.Fallback
+	}
+	if resolved.URL != link.URL {
+		resolved.Variant = ""
+	}
+
+	incoming := c.Request.URL.Query()
+	resolved.URL = passThrough(link, resolved.URL, req.Path, incoming)
+	if resolved.Fallback != "" {
+		resolved.Fallback = passThrough(link, resolved.Fallback, req.Path, incoming)
+	}
+	return resolved
+}
+
+// passThrough Append the wildcard suffix and merge the incoming query into the destination as configured on the link.
+// The destination is returned untouched when there is nothing to forward.
+func passThrough(link model.Link, destination string, suffix string, incoming url.Values) string {
+	if !link.ForwardQuery {
+		incoming = nil
+	} else {
+		incoming = cloneValues(incoming)
+		for _, param := range controlQueryParams {
+			incoming.Del(param)
+		}
+	}
+	suffix = strings.TrimPrefix(path.Clean(tool.ConcatStrings("/", suffix)), "/")
+
+	if len(incoming) == 0 && (!link.Wildcard || suffix == "") {
+		return destination
+	}
+
+	target, err := url.Parse(destination)
+	if err != nil {
+		return destination
+	}
+
+	if link.Wildcard && suffix != "" {
+		target.Path = tool.ConcatStrings(strings.TrimSuffix(target.Path, "/"), "/", suffix)
+		target.RawPath = ""
+	}
+
+	if len(incoming) > 0 {
+		precedence := link.QueryPrecedence
+		if precedence == "" {
+			precedence = setting.Cfg.Redirect.QueryPrecedence
+		}
+		query := target.Query()
+		for key, values := range incoming {
+			if _, exists := query[key]; exists && precedence != QueryPrecedenceIncoming {
+				continue
+			}
+			query[key] = values
+		}
+		target.RawQuery = query.Encode()
+	}
+
+	return target.String()
+}
+
+func cloneValues(values url.Values) url.Values {
+	cloned := make(url.Values, len(values))
+	for key, value := range values {
+		cloned[key] = append([]string(nil), value...)
+	}
+	return cloned
+}
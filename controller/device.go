@@ -0,0 +1,149 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"linkshortener/db"
+	"linkshortener/i18n"
+	"linkshortener/lib/tool"
+	"linkshortener/log"
+	"linkshortener/model"
+	"linkshortener/setting"
+	"net/http"
+	"strings"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// UpdateDeviceRules This method replaces the device targeted rules of a link
+// Usage:
+// Send http POST call to
+// {BasePath}/api/update_device_rules
+// An empty device_rules list removes the device targeting.
+func UpdateDeviceRules(c *gin.Context) {
+	var req model.UpdateDeviceRulesReq
+	localizer := i18n.GetLocalizer(c)
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		model.FailureResponse(c, http.StatusBadRequest, http.StatusBadRequest, localizer.GetMessage("deserializationFailed", nil), err.Error())
+		log.ErrorPrint("Deserialization failed: %s", err)
+		return
+	}
+
+	// Initialize session object
+	session := sessions.Default(c)
+	sessionCaptcha := tool.SafeSessionGet(session, "captcha")
+	session.Delete("captcha")
+	_ = session.Save()
+
+	if sessionCaptcha != req.CAPTCHA {
+		model.FailureResponse(c, http.StatusForbidden, http.StatusForbidden, localizer.GetMessage("captchaVerificationFailed", nil), "")
+		return
+	}
+
+	rules, err := normalizeDeviceRules(req.DeviceRules)
+	if err != nil {
+		model.FailureResponse(c, http.StatusBadRequest, http.StatusBadRequest, localizer.GetMessage("invalidDeviceRule", nil), err.Error())
+		return
+	}
+
+	var res []model.Link
+	table := db.SetModel(setting.Cfg.DB.Database, "links")
+	_ = table.Find(bson.D{{Key: "_id", Value: req.Hash}, {Key: "delete", Value: false}}, &res, db.Find().SetKey(req.Hash))
+
+	if res == nil || len(res) == 0 {
+		model.FailureResponse(c, http.StatusNotFound, http.StatusNotFound, localizer.GetMessage("noLinkFound", nil), "")
+		return
+	}
+	if lockedUntil, ok := checkManageToken(c, res[0], req.Token); !ok {
+		manageTokenFailure(c, localizer, lockedUntil)
+		return
+	}
+
+	err = table.UpdateByID(req.Hash, bson.M{
+		"$set": bson.M{
+			"device_rules": rules,
+		},
+	})
+	if err != nil {
+		model.FailureResponse(c, http.StatusInternalServerError, http.StatusInternalServerError, localizer.GetMessage("databaseOperationFailed", nil), "")
+		log.ErrorPrint("Update device rules of %s failed: %s", req.Hash, err)
+		return
+	}
+
+	model.SuccessResponse(c, map[string]interface{}{
+		"hash":         req.Hash,
+		"device_rules": rules,
+	})
+}
+
+// normalizeDeviceRules Check the rules, deep links are accepted when they come with a web fallback
+func normalizeDeviceRules(rules []model.DeviceRule) ([]model.DeviceRule, error) {
+	normalized := make([]model.DeviceRule, 0, len(rules))
+	for i, rule := range rules {
+		rule.OS = strings.TrimSpace(rule.OS)
+		rule.Device = strings.TrimSpace(rule.Device)
+		rule.Browser = strings.TrimSpace(rule.Browser)
+		if rule.OS == "" && rule.Device == "" && rule.Browser == "" {
+			return nil, fmt.Errorf("rule %d has no condition", i)
+		}
+
+		if rule.Fallback != "" {
+			fallback, err := normalizeDestination(rule.Fallback)
+			if err != nil {
+				return nil, fmt.Errorf("rule %d fallback: %w", i, err)
+			}
+			rule.Fallback = fallback
+		}
+
+		destination, err := normalizeDestination(rule.URL)
+		if err != nil && rule.Fallback != "" {
+			destination, err = normalizeDeepLink(rule.URL)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: %w", i, err)
+		}
+		rule.URL = destination
+		normalized = append(normalized, rule)
+	}
+	return normalized, nil
+}
+
+// normalizeDeepLink Encode an app deep link such as myapp://open/item/1
+func normalizeDeepLink(raw string) (string, error) {
+	parsedURL, err := tool.EncodeURI(raw)
+	if err != nil || parsedURL.Scheme == "" {
+		return "", errors.New("URL Parsed Failed")
+	}
+	if isUnsafeScheme(parsedURL.Scheme) {
+		return "", errors.New("Not Allowed Protocol")
+	}
+	return parsedURL.String(), nil
+}
+
+// deviceDestination The first rule matching the UA, ok is false when no rule matches
+func deviceDestination(link model.Link, uaInfo model.UAInfo) (rule model.DeviceRule, ok bool) {
+	for _, rule = range link.DeviceRules {
+		if matchDeviceRule(rule, uaInfo) {
+			return rule, true
+		}
+	}
+	return model.DeviceRule{}, false
+}
+
+// matchDeviceRule OS and browser families are compared as a whole, the device matches by substring
+// because uap reports it together with the brand, e.g. "Apple iPhone"
+func matchDeviceRule(rule model.DeviceRule, uaInfo model.UAInfo) bool {
+	if rule.OS != "" && !strings.EqualFold(rule.OS, uaInfo.OS) {
+		return false
+	}
+	if rule.Browser != "" && !strings.EqualFold(rule.Browser, uaInfo.Browser) {
+		return false
+	}
+	if rule.Device != "" && !strings.Contains(strings.ToLower(uaInfo.Device), strings.ToLower(rule.Device)) {
+		return false
+	}
+	return true
+}
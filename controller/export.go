@@ -0,0 +1,160 @@
+package controller
+
+import (
+	"linkshortener/db"
+	"linkshortener/i18n"
+	"linkshortener/lib/export"
+	"linkshortener/lib/tool"
+	"linkshortener/log"
+	"linkshortener/model"
+	"linkshortener/setting"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// exportFlushRows The number of rows written before the response is flushed to the client
+const exportFlushRows = 500
+
+var exportColumns = []string{
+	"hash", "created", "ip",
+	"country_iso_code", "country", "city", "isp", "organization", "autonomous_system_number", "autonomous_system_organization",
+	"browser", "browser_version", "os", "os_version", "device", "variant",
+}
+
+// ExportStatsLink This method downloads all access records of a link as a CSV or XLSX file
+// Usage:
+// Send http POST call to
+// {BasePath}/api/export_stats_link
+func ExportStatsLink(c *gin.Context) {
+	var req model.ManageLinkReq
+	localizer := i18n.GetLocalizer(c)
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		model.FailureResponse(c, http.StatusBadRequest, http.StatusBadRequest, localizer.GetMessage("deserializationFailed", nil), err.Error())
+		log.ErrorPrint("Deserialization failed: %s", err)
+		return
+	}
+
+	if req.From != 0 && req.To != 0 && req.From > req.To {
+		model.FailureResponse(c, http.StatusBadRequest, http.StatusBadRequest, localizer.GetMessage("invalidFilterParameter", nil), "from is later than to")
+		return
+	}
+
+	format := tool.If(req.Format == "", export.FormatCSV, req.Format).(string)
+
+	// Initialize session object
+	session := sessions.Default(c)
+	sessionCaptcha := tool.SafeSessionGet(session, "captcha")
+
+	if sessionCaptcha != req.CAPTCHA {
+		session.Delete("captcha")
+		_ = session.Save()
+		model.FailureResponse(c, http.StatusForbidden, http.StatusForbidden, localizer.GetMessage("captchaVerificationFailed", nil), "")
+		return
+	}
+
+	var res []model.Link
+	table := db.SetModel(setting.Cfg.DB.Database, "links")
+	_ = table.Find(bson.D{{Key: "_id", Value: req.Hash}}, &res, db.Find().SetKey(req.Hash))
+
+	if res == nil || len(res) == 0 {
+		session.Delete("captcha")
+		_ = session.Save()
+		model.FailureResponse(c, http.StatusNotFound, http.StatusNotFound, localizer.GetMessage("noLinkFound", nil), "")
+		return
+	}
+	if lockedUntil, ok := checkManageToken(c, res[0], req.Token); !ok {
+		session.Delete("captcha")
+		_ = session.Save()
+		manageTokenFailure(c, localizer, lockedUntil)
+		return
+	}
+
+	statsFilter, appliedFilters := statsLinkFilter(req)
+	sortOrder := tool.If(appliedFilters["sort"] == "desc", -1, 1).(int)
+	findOptions := db.Find().SetKey(tool.ConcatStrings(req.Hash, ":")).SetPrefixScans(true).
+		SetSort(bson.D{{Key: "created", Value: sortOrder}}).SetReverse(sortOrder < 0)
+
+	contentType, err := export.ContentType(format)
+	if err != nil {
+		model.FailureResponse(c, http.StatusBadRequest, http.StatusBadRequest, localizer.GetMessage("deserializationFailed", nil), err.Error())
+		return
+	}
+
+	// The headers are set before the writer is created, the first write commits the response
+	filename := tool.ConcatStrings("lls-stats-", req.Hash, "-", time.Now().UTC().Format("20060102"), ".", format)
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", tool.ConcatStrings(`attachment; filename="`, filename, `"`))
+	c.Header("Cache-Control", "no-store")
+
+	writer, err := export.NewRowWriter(format, c.Writer)
+	if err != nil {
+		c.Writer.Header().Del("Content-Type")
+		c.Writer.Header().Del("Content-Disposition")
+		model.FailureResponse(c, http.StatusInternalServerError, http.StatusInternalServerError, localizer.GetMessage("exportFailed", nil), "")
+		log.ErrorPrint("Export writer creation failed: %s", err)
+		return
+	}
+	c.Status(http.StatusOK)
+
+	// From here on the response is streamed, the status can no longer report an error
+	rows := 0
+	err = writer.WriteRow(exportColumns)
+	if err == nil {
+		statsTable := db.SetModel(setting.Cfg.DB.Database, "link_access")
+		err = statsTable.FindEach(statsFilter, findOptions, func(decode func(result interface{}) error) error {
+			var linkInfo model.LinkInfo
+			if err := decode(&linkInfo); err != nil {
+				return err
+			}
+			if err := writer.WriteRow(exportRow(linkInfo)); err != nil {
+				return err
+			}
+			rows++
+			if rows%exportFlushRows == 0 {
+				if err := writer.Flush(); err != nil {
+					return err
+				}
+				c.Writer.Flush()
+			}
+			return nil
+		})
+	}
+	if err != nil {
+		log.ErrorPrint("Export stats of %s failed after %d rows: %s", req.Hash, rows, err)
+		// Closing the file would make the rows written so far look like the whole export,
+		// the connection is dropped instead so the download fails on the client
+		panic(http.ErrAbortHandler)
+	}
+	if err = writer.Close(); err != nil {
+		log.ErrorPrint("Export writer close failed: %s", err)
+	}
+	c.Writer.Flush()
+}
+
+// exportRow Flatten the access record, raw request headers are never exported
+func exportRow(linkInfo model.LinkInfo) []string {
+	return []string{
+		linkInfo.Hash,
+		time.Unix(linkInfo.Created, 0).UTC().Format(time.RFC3339),
+		linkInfo.IP,
+		linkInfo.CountryIsoCode,
+		linkInfo.Country,
+		linkInfo.City,
+		linkInfo.ISP,
+		linkInfo.Organization,
+		strconv.FormatUint(uint64(linkInfo.AutonomousSystemNumber), 10),
+		linkInfo.AutonomousSystemOrganization,
+		linkInfo.Browser,
+		linkInfo.BrowserVersion,
+		linkInfo.OS,
+		linkInfo.OSVersion,
+		linkInfo.Device,
+		linkInfo.Variant,
+	}
+}
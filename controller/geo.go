@@ -0,0 +1,184 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"linkshortener/db"
+	"linkshortener/i18n"
+	"linkshortener/lib/blocklist"
+	"linkshortener/lib/domainlist"
+	"linkshortener/lib/tool"
+	"linkshortener/lib/urlpolicy"
+	"linkshortener/log"
+	"linkshortener/model"
+	"linkshortener/setting"
+	"net/http"
+	"strings"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// UpdateGeoRules This method replaces the geo-targeted rules and the fallback URL of a link
+// Usage:
+// Send http POST call to
+// {BasePath}/api/update_geo_rules
+// An empty geo_rules list removes the geo targeting.
+func UpdateGeoRules(c *gin.Context) {
+	var req model.UpdateGeoRulesReq
+	localizer := i18n.GetLocalizer(c)
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		model.FailureResponse(c, http.StatusBadRequest, http.StatusBadRequest, localizer.GetMessage("deserializationFailed", nil), err.Error())
+		log.ErrorPrint("Deserialization failed: %s", err)
+		return
+	}
+
+	// Initialize session object
+	session := sessions.Default(c)
+	sessionCaptcha := tool.SafeSessionGet(session, "captcha")
+	session.Delete("captcha")
+	_ = session.Save()
+
+	if sessionCaptcha != req.CAPTCHA {
+		model.FailureResponse(c, http.StatusForbidden, http.StatusForbidden, localizer.GetMessage("captchaVerificationFailed", nil), "")
+		return
+	}
+
+	rules, fallback, err := normalizeGeoRules(req.GeoRules, req.GeoFallback)
+	if err != nil {
+		model.FailureResponse(c, http.StatusBadRequest, http.StatusBadRequest, localizer.GetMessage("invalidGeoRule", nil), err.Error())
+		return
+	}
+
+	var res []model.Link
+	table := db.SetModel(setting.Cfg.DB.Database, "links")
+	_ = table.Find(bson.D{{Key: "_id", Value: req.Hash}, {Key: "delete", Value: false}}, &res, db.Find().SetKey(req.Hash))
+
+	if res == nil || len(res) == 0 {
+		model.FailureResponse(c, http.StatusNotFound, http.StatusNotFound, localizer.GetMessage("noLinkFound", nil), "")
+		return
+	}
+	if lockedUntil, ok := checkManageToken(c, res[0], req.Token); !ok {
+		manageTokenFailure(c, localizer, lockedUntil)
+		return
+	}
+
+	err = table.UpdateByID(req.Hash, bson.M{
+		"$set": bson.M{
+			"geo_rules":    rules,
+			"geo_fallback": fallback,
+		},
+	})
+	if err != nil {
+		model.FailureResponse(c, http.StatusInternalServerError, http.StatusInternalServerError, localizer.GetMessage("databaseOperationFailed", nil), "")
+		log.ErrorPrint("Update geo rules of %s failed: %s", req.Hash, err)
+		return
+	}
+
+	model.SuccessResponse(c, map[string]interface{}{
+		"hash":         req.Hash,
+		"geo_rules":    rules,
+		"geo_fallback": fallback,
+	})
+}
+
+// normalizeGeoRules Check the rules and encode their destinations the same way as the link URL
+func normalizeGeoRules(rules []model.GeoRule, fallback string) ([]model.GeoRule, string, error) {
+	normalized := make([]model.GeoRule, 0, len(rules))
+	for i, rule := range rules {
+		rule.CountryIsoCode = strings.ToUpper(rule.CountryIsoCode)
+		rule.City = strings.TrimSpace(rule.City)
+		if rule.CountryIsoCode == "" && rule.City == "" && rule.AutonomousSystemNumber == 0 {
+			return nil, "", fmt.Errorf("rule %d has no condition", i)
+		}
+		destination, err := normalizeDestination(rule.URL)
+		if err != nil {
+			return nil, "", fmt.Errorf("rule %d: %w", i, err)
+		}
+		rule.URL = destination
+		normalized = append(normalized, rule)
+	}
+
+	if fallback != "" {
+		destination, err := normalizeDestination(fallback)
+		if err != nil {
+			return nil, "", fmt.Errorf("geo_fallback: %w", err)
+		}
+		fallback = destination
+	}
+	return normalized, fallback, nil
+}
+
+// normalizeDestination Encode a destination URL and check its protocol is allowed
+func normalizeDestination(raw string) (string, error) {
+	parsedURL, err := tool.EncodeURI(raw)
+	if err != nil {
+		return "", errors.New("URL Parsed Failed")
+	}
+	destination := parsedURL.String()
+	if err = urlpolicy.Check(parsedURL); err != nil {
+		return "", err
+	}
+	if err = domainlist.Check(parsedURL.Hostname()); err != nil {
+		return "", fmt.Errorf("Blocked Domain %s: %w", parsedURL.Hostname(), err)
+	}
+	if reason, listed := blocklist.CheckURL(destination); listed {
+		return "", fmt.Errorf("Listed URL: %s", reason)
+	}
+	return destination, nil
+}
+
+// destinationPolicyDetail Explain in the language of the visitor why the destination was refused by the policy
+func destinationPolicyDetail(localizer i18n.ITranslator, err error) string {
+	switch {
+	case errors.Is(err, urlpolicy.ErrScheme):
+		return localizer.GetMessage("destinationSchemeNotAllowed", nil)
+	case errors.Is(err, urlpolicy.ErrUserinfo):
+		return localizer.GetMessage("destinationCredentials", nil)
+	case errors.Is(err, urlpolicy.ErrUnresolvable):
+		return localizer.GetMessage("destinationUnresolvable", nil)
+	default:
+		return localizer.GetMessage("destinationRestrictedAddress", nil)
+	}
+}
+
+// domainBlockedDetail Explain in the language of the visitor why the destination host was refused
+func domainBlockedDetail(localizer i18n.ITranslator, host string, err error) string {
+	data := map[string]interface{}{"Host": domainlist.NormalizeHost(host)}
+	if errors.Is(err, domainlist.ErrNotAllowed) {
+		return localizer.GetMessage("domainNotAllowed", data)
+	}
+	return localizer.GetMessage("domainDenied", data)
+}
+
+// geoDestination The destination of the first rule matching the location.
+// When no rule matches the geo fallback is used, or the link URL if there is none.
+func geoDestination(link model.Link, location model.Location) string {
+	if len(link.GeoRules) == 0 {
+		return link.URL
+	}
+	for _, rule := range link.GeoRules {
+		if matchGeoRule(rule, location) {
+			return rule.URL
+		}
+	}
+	if link.GeoFallback != "" {
+		return link.GeoFallback
+	}
+	return link.URL
+}
+
+func matchGeoRule(rule model.GeoRule, location model.Location) bool {
+	if rule.CountryIsoCode != "" && !strings.EqualFold(rule.CountryIsoCode, location.CountryIsoCode) {
+		return false
+	}
+	if rule.City != "" && !strings.EqualFold(rule.City, location.City) {
+		return false
+	}
+	if rule.AutonomousSystemNumber != 0 && rule.AutonomousSystemNumber != location.AutonomousSystemNumber {
+		return false
+	}
+	return true
+}
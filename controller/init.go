@@ -5,11 +5,13 @@ import (
 	"linkshortener/fs"
 	"linkshortener/i18n"
 	"linkshortener/lib/lfs"
+	"linkshortener/lib/pubsub"
 	"linkshortener/lib/tool"
 	"linkshortener/log"
 	"linkshortener/model"
 	"linkshortener/setting"
 	"net/http"
+	"net/url"
 	"strings"
 	"sync"
 	"time"
@@ -35,7 +37,7 @@ func ReqLogger() gin.HandlerFunc {
 
 		reqMethod := c.Request.Method
 
-		reqUri := c.Request.RequestURI
+		reqUri := redactRequestURI(c.Request.URL)
 
 		statusCode := c.Writer.Status()
 
@@ -51,6 +53,33 @@ func ReqLogger() gin.HandlerFunc {
 	}
 }
 
+// sensitiveQueryParams Query parameters whose values are replaced before the request is logged
+var sensitiveQueryParams = []string{"token", "pwd"}
+
+// redactRequestURI Return the request URI with the values of sensitive query parameters masked
+func redactRequestURI(u *url.URL) string {
+	if u.RawQuery == "" {
+		return u.RequestURI()
+	}
+	query := u.Query()
+	redacted := false
+	for _, param := range sensitiveQueryParams {
+		if query.Has(param) {
+			query.Set(param, "***")
+			redacted = true
+		}
+	}
+	if !redacted {
+		return u.RequestURI()
+	}
+	return tool.ConcatStrings(u.EscapedPath(), "?", query.Encode())
+}
+
+// Router The handler of the server, available after InitController
+func Router() http.Handler {
+	return router
+}
+
 func InitRouter() {
 	BasePath := strings.TrimPrefix(strings.TrimSuffix(strings.Join(strings.Fields(setting.Cfg.HTTP.BasePath), ""), "/"), "/")
 	if BasePath != "" {
@@ -66,12 +95,31 @@ func InitRouter() {
 
 	router.GET(tool.ConcatStrings(BasePath, "/ping"), Ping) //Service Test Interface
 
-	router.GET(tool.ConcatStrings(BasePath, "/s/:hash"), Redirect) //Short link redirection
+	router.GET(tool.ConcatStrings(BasePath, "/s/:hash"), Redirect)          //Short link redirection
+	router.GET(tool.ConcatStrings(BasePath, "/s/:hash/*path"), Redirect)    //Wildcard short link redirection
+	router.POST(tool.ConcatStrings(BasePath, "/s/:hash"), UnlockLink)       //Password page submission
+	router.POST(tool.ConcatStrings(BasePath, "/s/:hash/*path"), UnlockLink) //Password page submission of wildcard links
+
+	router.GET(tool.ConcatStrings(BasePath, "/api/captcha"), Captcha)                        //Generate captcha code
+	router.POST(tool.ConcatStrings(BasePath, "/api/generate_link"), GenerateLink)            //Create link
+	router.POST(tool.ConcatStrings(BasePath, "/api/stats_link"), StatsLink)                  //Link statistics
+	router.POST(tool.ConcatStrings(BasePath, "/api/export_stats_link"), ExportStatsLink)     //Download link statistics
+	router.POST(tool.ConcatStrings(BasePath, "/api/delete_link"), DeleteLink)                //Delete link
+	router.POST(tool.ConcatStrings(BasePath, "/api/rotate_token"), RotateToken)              //Replace the manage token
+	router.POST(tool.ConcatStrings(BasePath, "/api/update_geo_rules"), UpdateGeoRules)       //Edit geo-targeted rules
+	router.POST(tool.ConcatStrings(BasePath, "/api/update_device_rules"), UpdateDeviceRules) //Edit device targeted rules
+
+	if setting.Cfg.Stream.EnableStream {
+		router.GET(tool.ConcatStrings(BasePath, "/api/stream_link/:hash"), StreamLink) //Real-time click stream
+	}
+
+	if setting.Cfg.Bulk.EnableBulk {
+		router.POST(tool.ConcatStrings(BasePath, "/api/bulk_generate_link"), BulkGenerateLinks) //Create links in bulk with an API key
+	}
 
-	router.GET(tool.ConcatStrings(BasePath, "/api/captcha"), Captcha)             //Generate captcha code
-	router.POST(tool.ConcatStrings(BasePath, "/api/generate_link"), GenerateLink) //Create link
-	router.POST(tool.ConcatStrings(BasePath, "/api/stats_link"), StatsLink)       //Link statistics
-	router.POST(tool.ConcatStrings(BasePath, "/api/delete_link"), DeleteLink)     //Delete link
+	if setting.Cfg.QR.EnableQR {
+		router.GET(tool.ConcatStrings(BasePath, "/api/qr/:hash"), QRCode) //QR code of the short link
+	}
 
 	if setting.Cfg.HTTP.DisableFilesDirEmbed { //Static files
 		if strings.Join(strings.Fields(setting.Cfg.HTTP.FilesDirURI), "") != "" {
@@ -119,7 +167,7 @@ func LooseCORS() gin.HandlerFunc {
 		if origin != "" {
 			c.Header("Access-Control-Allow-Origin", "*")
 			c.Header("Access-Control-Allow-Methods", "POST, GET, OPTIONS")
-			c.Header("Access-Control-Allow-Headers", "Origin, X-Requested-With, Content-Type, Accept, Authorization")
+			c.Header("Access-Control-Allow-Headers", "Origin, X-Requested-With, Content-Type, Accept, Authorization, Idempotency-Key")
 			c.Header("Access-Control-Expose-Headers", "Content-Length, Access-Control-Allow-Origin, Access-Control-Allow-Headers, Cache-Control, Content-Language, Content-Type")
 			c.Header("Access-Control-Allow-Credentials", "true")
 		}
@@ -139,7 +187,17 @@ func InitController() {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
+	if setting.Cfg.Redirect.DefaultRedirectType != 0 && !IsRedirectType(setting.Cfg.Redirect.DefaultRedirectType) {
+		log.PanicPrint("DEFAULT_REDIRECT_TYPE is only allowed to be 301|302|307|308")
+	}
+	switch strings.ToUpper(setting.Cfg.Redirect.InterstitialMode) {
+	case "", InterstitialModeNone, InterstitialModeNonHTTP, InterstitialModeAll:
+	default:
+		log.PanicPrint("INTERSTITIAL_MODE is only allowed to be NONE|NON_HTTP|ALL")
+	}
+
 	router = gin.New()
+	clickHub = pubsub.NewHub(setting.Cfg.Stream.SubscriberBuffer, setting.Cfg.Stream.MaxSubscribers)
 
 	if setting.Cfg.HTTP.LooseCORS {
 		router.Use(LooseCORS())
@@ -159,6 +217,12 @@ func InitController() {
 	if !setting.Cfg.HTTP.RandomSessionSecret {
 		SessionSecret = setting.Cfg.HTTP.SessionSecret
 	}
+	unlockKey = []byte(SessionSecret)
+	if len(unlockKey) == 0 {
+		// An empty key would let anyone sign unlock cookies
+		randomKey, _ := tool.GetToken(32)
+		unlockKey = []byte(randomKey)
+	}
 	store := memstore.NewStore([]byte(SessionSecret))
 	router.Use(sessions.Sessions("session", store))
 
@@ -1,22 +1,29 @@
 package controller
 
 import (
+	"errors"
 	"linkshortener/db"
 	"linkshortener/i18n"
+	"linkshortener/lib/blocklist"
+	"linkshortener/lib/domainlist"
+	"linkshortener/lib/passhash"
 	"linkshortener/lib/shorten"
 	"linkshortener/lib/tool"
+	"linkshortener/lib/urlpolicy"
 	"linkshortener/log"
 	"linkshortener/model"
 	"linkshortener/setting"
 	"net/http"
 	"net/url"
-	"strings"
 	"time"
 
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
 )
 
+// saveLinkRetries Generated hashes tried again when they are already taken
+const saveLinkRetries = 3
+
 // GenerateLink This method saves the redirection into the mongo database.
 //
 // Usage:
@@ -48,42 +55,115 @@ func GenerateLink(c *gin.Context) {
 		return
 	}
 
+	if linkErr := prepareLink(localizer, &req, now); linkErr != nil {
+		model.FailureResponse(c, linkErr.status, linkErr.status, linkErr.message, linkErr.detail)
+		return
+	}
+
+	link, token, err := saveLink(req, "")
+	if err != nil {
+		model.FailureResponse(c, http.StatusInternalServerError, http.StatusInternalServerError, localizer.GetMessage("databaseOperationFailed", nil), "")
+		return
+	}
+
+	log.DebugPrint("SrcLink: %s, GenerateShortenLink: %s/s/%s", req.URL, setting.Cfg.HTTP.BasePath, link.ShortHash)
+
+	data := map[string]interface{}{
+		"hash":  link.ShortHash,
+		"token": token,
+	}
+
+	model.SuccessResponse(c, data)
+}
+
+// linkError A link refused by the checks of prepareLink, answered with FailureResponse
+type linkError struct {
+	status  int
+	message string
+	detail  string
+}
+
+func badLink(localizer i18n.ITranslator, messageID string, detail string) *linkError {
+	return &linkError{status: http.StatusBadRequest, message: localizer.GetMessage(messageID, nil), detail: detail}
+}
+
+// prepareLink Check the destination and the options of a new link and normalize them like they are stored
+func prepareLink(localizer i18n.ITranslator, req *model.InsertLinkReq, now int64) *linkError {
 	parsedURL, err := tool.EncodeURI(req.URL)
 	if err != nil {
-		model.FailureResponse(c, http.StatusBadRequest, http.StatusBadRequest, localizer.GetMessage("invalidUrl", nil), "URL Parsed Failed")
 		log.ErrorPrint("URL Parsed failed: %s", err)
-		return
+		return badLink(localizer, "invalidUrl", "URL Parsed Failed")
 	}
 
 	req.URL = parsedURL.String()
 	req.MEMO = url.QueryEscape(req.MEMO)
 
+	if req.Preview.Image != "" && !isHTTPURL(req.Preview.Image) {
+		return badLink(localizer, "invalidUrl", "preview.image must be an http(s) URL")
+	}
+
 	if req.EXPIRE != 0 && req.EXPIRE < now {
-		model.FailureResponse(c, http.StatusBadRequest, http.StatusBadRequest, localizer.GetMessage("illegalExpirationTime", nil), "")
-		return
+		return badLink(localizer, "illegalExpirationTime", "")
 	}
 
-	if !setting.Cfg.AllowAllProtocol && !strings.HasPrefix(req.URL, "http://") && !strings.HasPrefix(req.URL, "https://") {
-		model.FailureResponse(c, http.StatusBadRequest, http.StatusBadRequest, localizer.GetMessage("invalidUrl", nil), "Not Allowed Protocol")
-		log.WarnPrint("Illegal URL: %s", req.URL)
-		return
+	if req.ACTIVATE != 0 && req.EXPIRE != 0 && req.ACTIVATE >= req.EXPIRE {
+		return badLink(localizer, "illegalActivationTime", "activate_at is not earlier than expire")
+	}
+
+	if err = urlpolicy.Check(parsedURL); err != nil {
+		log.WarnPrint("Illegal URL: %s (%s)", req.URL, err)
+		return badLink(localizer, "invalidUrl", destinationPolicyDetail(localizer, err))
 	}
 
-	link := shorten.GenerateShortenLink(req)
+	if err = domainlist.Check(parsedURL.Hostname()); err != nil {
+		log.WarnPrint("Blocked URL: %s (%s)", req.URL, err)
+		return badLink(localizer, "invalidUrl", domainBlockedDetail(localizer, parsedURL.Hostname(), err))
+	}
 
-	table := db.SetModel(setting.Cfg.DB.Database, "links")
-	_, err = table.InsertOne(link, false)
+	if reason, listed := blocklist.CheckURL(req.URL); listed {
+		log.WarnPrint("Listed URL: %s (%s)", req.URL, reason)
+		return badLink(localizer, "invalidUrl", localizer.GetMessage("urlListedByThreatFeed", nil))
+	}
+
+	req.GeoRules, req.GeoFallback, err = normalizeGeoRules(req.GeoRules, req.GeoFallback)
 	if err != nil {
-		model.FailureResponse(c, http.StatusInternalServerError, http.StatusInternalServerError, localizer.GetMessage("databaseOperationFailed", nil), "")
-		return
+		return badLink(localizer, "invalidGeoRule", err.Error())
 	}
 
-	log.DebugPrint("SrcLink: %s, GenerateShortenLink: %s/s/%s", req.URL, setting.Cfg.HTTP.BasePath, link.ShortHash)
+	req.DeviceRules, err = normalizeDeviceRules(req.DeviceRules)
+	if err != nil {
+		return badLink(localizer, "invalidDeviceRule", err.Error())
+	}
 
-	data := map[string]interface{}{
-		"hash":  link.ShortHash,
-		"token": link.Token,
+	req.ScheduleRules, err = normalizeScheduleRules(req.ScheduleRules)
+	if err != nil {
+		return badLink(localizer, "invalidScheduleRule", err.Error())
 	}
 
-	model.SuccessResponse(c, data)
+	req.Variants, err = normalizeVariants(req.Variants)
+	if err != nil {
+		return badLink(localizer, "invalidVariant", err.Error())
+	}
+	return nil
+}
+
+// saveLink Store a link checked by prepareLink under the alias, or under a generated hash when alias is empty.
+// The manage token is returned once, only its keyed hash is stored. A taken alias fails with db.ErrDuplicateKey.
+func saveLink(req model.InsertLinkReq, alias string) (link model.Link, token string, err error) {
+	table := db.SetModel(setting.Cfg.DB.Database, "links")
+	for attempt := 0; ; attempt++ {
+		link = shorten.GenerateShortenLink(req)
+		if alias != "" {
+			link.ShortHash = alias
+		}
+		token = link.Token
+		link.Token = passhash.HashToken(token)
+
+		_, err = table.InsertOne(link, false, db.Insert().SetFailIfExists(true))
+		// Generated hashes are retried, they rarely collide with an older link or an alias
+		if alias == "" && errors.Is(err, db.ErrDuplicateKey) && attempt < saveLinkRetries {
+			continue
+		}
+		return link, token, err
+	}
 }
@@ -0,0 +1,106 @@
+package controller
+
+import (
+	"html/template"
+	"linkshortener/i18n"
+	"linkshortener/lib/page"
+	"linkshortener/lib/tool"
+	"linkshortener/lib/urlpolicy"
+	"linkshortener/log"
+	"linkshortener/model"
+	"linkshortener/setting"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	InterstitialModeNone    = "NONE"
+	InterstitialModeNonHTTP = "NON_HTTP"
+	InterstitialModeAll     = "ALL"
+)
+
+// interstitialPage The data of the interstitial template
+type interstitialPage struct {
+	Lang           string
+	Title          string
+	Notice         string
+	Domain         string
+	Memo           string
+	MemoLabel      string
+	ProtocolNotice string
+	Destination    template.URL
+	Continue       string
+	Blocked        string
+}
+
+// useInterstitial Reports whether the server-rendered interstitial replaces the redirect or the soft redirect UI
+func useInterstitial(link model.Link, req model.RedirectLinkReq, destination string) bool {
+	if link.Interstitial {
+		return true
+	}
+	switch strings.ToUpper(setting.Cfg.Redirect.InterstitialMode) {
+	case InterstitialModeAll:
+		return true
+	case InterstitialModeNonHTTP:
+		return req.Soft || !isHTTPURL(destination)
+	default:
+		return false
+	}
+}
+
+// renderInterstitial Show the destination domain and memo of the link with a button to continue
+func renderInterstitial(c *gin.Context, localizer i18n.ITranslator, link model.Link, destination string) {
+	data := interstitialPage{
+		Lang:      localizer.GetMessage("htmlLang", nil),
+		Title:     localizer.GetMessage("interstitialTitle", nil),
+		MemoLabel: localizer.GetMessage("interstitialMemo", nil),
+		Continue:  localizer.GetMessage("interstitialContinue", nil),
+		Blocked:   localizer.GetMessage("interstitialBlocked", nil),
+	}
+
+	// The memo is stored query escaped by GenerateLink
+	if memo, err := url.QueryUnescape(link.Memo); err == nil {
+		data.Memo = memo
+	} else {
+		data.Memo = link.Memo
+	}
+
+	parsedURL, err := url.Parse(destination)
+	if err != nil || parsedURL.Scheme == "" {
+		data.Domain = destination
+	} else {
+		data.Domain = parsedURL.Host
+		if data.Domain == "" {
+			data.Domain = tool.ConcatStrings(parsedURL.Scheme, ":")
+		}
+		if !isHTTPURL(destination) {
+			data.ProtocolNotice = localizer.GetMessage("interstitialProtocolNotice", map[string]interface{}{"Scheme": parsedURL.Scheme})
+		}
+		// html/template only lets http(s) and mailto links through, other schemes are checked here instead
+		if !isUnsafeScheme(parsedURL.Scheme) {
+			data.Destination = template.URL(parsedURL.String())
+		}
+	}
+	data.Notice = localizer.GetMessage("interstitialNotice", nil)
+
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Header("Cache-Control", "no-store")
+	c.Header("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; base-uri 'none'; form-action 'none'; frame-ancestors 'none'")
+	c.Header("Referrer-Policy", "no-referrer")
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Status(http.StatusOK)
+	if err = page.Render(c.Writer, "interstitial", data); err != nil {
+		log.ErrorPrint("Render interstitial of %s failed: %s", link.ShortHash, err)
+	}
+}
+
+func isHTTPURL(destination string) bool {
+	return strings.HasPrefix(destination, "http://") || strings.HasPrefix(destination, "https://")
+}
+
+func isUnsafeScheme(scheme string) bool {
+	return urlpolicy.IsUnsafeScheme(scheme)
+}
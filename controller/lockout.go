@@ -0,0 +1,76 @@
+package controller
+
+import (
+	"linkshortener/db"
+	"linkshortener/i18n"
+	"linkshortener/lib/passhash"
+	"linkshortener/lib/tool"
+	"linkshortener/model"
+	"linkshortener/setting"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	lockoutKindPassword = "password"
+	lockoutKindToken    = "token"
+	lockoutKindAPIKey   = "apikey"
+)
+
+// lockoutSubjects Failures are tracked per client IP. Counting them per link as well also stops distributed guessing,
+// but lets anyone lock the owner out of the link, so it is only done when PER_LINK is enabled.
+func lockoutSubjects(kind string, hash string, ip string) []string {
+	subjects := []string{tool.ConcatStrings(kind, ":ip:", ip)}
+	if setting.Cfg.Lockout.PerLink {
+		subjects = append(subjects, tool.ConcatStrings(kind, ":hash:", hash))
+	}
+	return subjects
+}
+
+// checkAttempt Run the check unless the subjects are locked out and record the outcome.
+// lockedUntil is set when the check was refused or its failure caused a lockout.
+func checkAttempt(c *gin.Context, kind string, hash string, check func() bool) (lockedUntil int64, ok bool) {
+	now := time.Now()
+	subjects := lockoutSubjects(kind, hash, c.ClientIP())
+	if lockedUntil = db.LockedUntil(now, subjects...); lockedUntil > 0 {
+		return lockedUntil, false
+	}
+	if !check() {
+		return db.RecordFailure(now, subjects...), false
+	}
+	db.ResetFailures(subjects...)
+	return 0, true
+}
+
+// checkPasswordAttempt Verify the password of the link with brute-force protection
+func checkPasswordAttempt(c *gin.Context, link *model.Link, password string) (lockedUntil int64, ok bool) {
+	return checkAttempt(c, lockoutKindPassword, link.ShortHash, func() bool {
+		return checkLinkPassword(link, password)
+	})
+}
+
+// checkManageToken Verify the manage token of the link with brute-force protection
+func checkManageToken(c *gin.Context, link model.Link, token string) (lockedUntil int64, ok bool) {
+	return checkAttempt(c, lockoutKindToken, link.ShortHash, func() bool {
+		return passhash.VerifyToken(link.Token, token)
+	})
+}
+
+// manageTokenFailure Respond to a refused manage token, 429 while locked out
+func manageTokenFailure(c *gin.Context, localizer i18n.ITranslator, lockedUntil int64) {
+	if lockedUntil > 0 {
+		tooManyAttemptsResponse(c, localizer, lockedUntil)
+		return
+	}
+	model.FailureResponse(c, http.StatusForbidden, http.StatusForbidden, localizer.GetMessage("passwordVerificationFailed", nil), "")
+}
+
+// tooManyAttemptsResponse The lockout error, the detail is the number of seconds left
+func tooManyAttemptsResponse(c *gin.Context, localizer i18n.ITranslator, lockedUntil int64) {
+	retryAfter := strconv.FormatInt(max(lockedUntil-time.Now().Unix(), 1), 10)
+	c.Header("Retry-After", retryAfter)
+	model.FailureResponse(c, http.StatusTooManyRequests, http.StatusTooManyRequests, localizer.GetMessage("tooManyAttempts", nil), retryAfter)
+}
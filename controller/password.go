@@ -0,0 +1,174 @@
+package controller
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"linkshortener/db"
+	"linkshortener/i18n"
+	"linkshortener/lib/page"
+	"linkshortener/lib/passhash"
+	"linkshortener/lib/tool"
+	"linkshortener/log"
+	"linkshortener/model"
+	"linkshortener/setting"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// defaultUnlockMaxAge Seconds an unlock cookie grants access when UNLOCK_MAX_AGE is not set
+const defaultUnlockMaxAge = 600
+
+// unlockKey Signs the unlock cookies, it is derived from the session secret in InitController
+var unlockKey []byte
+
+// passwordPage The data of the password template
+type passwordPage struct {
+	Lang   string
+	Title  string
+	Prompt string
+	Label  string
+	Submit string
+	Error  string
+}
+
+// UnlockLink This method checks the password posted by the password page and grants access to the link with a signed cookie
+// Usage:
+// The password page posts the form field "pwd" to the URL of the short link,
+// {BasePath}/s/:hash (or {BasePath}/s/:hash/*path), and is redirected back to it on success.
+func UnlockLink(c *gin.Context) {
+	req := model.RedirectLinkReq{}
+	localizer := i18n.GetLocalizer(c)
+
+	if err := c.ShouldBindUri(&req); err != nil {
+		log.ErrorPrint("Deserialization failed: %s", err)
+		c.Redirect(http.StatusSeeOther, tool.ConcatStrings(setting.Cfg.HTTP.SoftRedirectBasePath, "/#/Error/DeserializationFailed"))
+		return
+	}
+	password := c.PostForm("pwd")
+
+	var res []model.Link
+	table := db.SetModel(setting.Cfg.DB.Database, "links")
+	_ = table.Find(bson.D{{Key: "_id", Value: req.Hash}, {Key: "delete", Value: false}}, &res, db.Find().SetKey(req.Hash))
+
+	// Anything but a wrong password is handled by Redirect on the way back
+	if res != nil && len(res) == 1 && res[0].Password != "" {
+		link := res[0]
+		if password == "" {
+			renderPasswordPage(c, localizer, http.StatusUnauthorized, localizer.GetMessage("linkPasswordError", nil))
+			return
+		}
+		if lockedUntil, ok := checkPasswordAttempt(c, &link, password); !ok {
+			if lockedUntil > 0 {
+				c.Header("Retry-After", strconv.FormatInt(max(lockedUntil-time.Now().Unix(), 1), 10))
+				renderPasswordPage(c, localizer, http.StatusTooManyRequests, localizer.GetMessage("tooManyAttempts", nil))
+				return
+			}
+			log.DebugPrint("password error: %s", req.Hash)
+			renderPasswordPage(c, localizer, http.StatusUnauthorized, localizer.GetMessage("linkPasswordError", nil))
+			return
+		}
+
+		maxAge := setting.Cfg.Redirect.UnlockMaxAge
+		if maxAge <= 0 {
+			maxAge = defaultUnlockMaxAge
+		}
+		c.SetSameSite(http.SameSiteLaxMode)
+		c.SetCookie(unlockCookieName(link.ShortHash), signUnlock(link, time.Now().Add(time.Duration(maxAge)*time.Second).Unix()),
+			maxAge, tool.ConcatStrings(setting.Cfg.HTTP.BasePath, "/s/", link.ShortHash), "", secureCookie(c), true)
+	}
+
+	c.Redirect(http.StatusSeeOther, withoutPasswordQuery(c.Request.URL))
+}
+
+// renderPasswordPage Show the password form, it posts back to the current URL
+func renderPasswordPage(c *gin.Context, localizer i18n.ITranslator, status int, errorMessage string) {
+	data := passwordPage{
+		Lang:   localizer.GetMessage("htmlLang", nil),
+		Title:  localizer.GetMessage("passwordPageTitle", nil),
+		Prompt: localizer.GetMessage("passwordPagePrompt", nil),
+		Label:  localizer.GetMessage("passwordPageLabel", nil),
+		Submit: localizer.GetMessage("passwordPageSubmit", nil),
+		Error:  errorMessage,
+	}
+
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Header("Cache-Control", "no-store")
+	c.Header("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; base-uri 'none'; form-action 'self'; frame-ancestors 'none'")
+	c.Header("Referrer-Policy", "no-referrer")
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Status(status)
+	if err := page.Render(c.Writer, "password", data); err != nil {
+		log.ErrorPrint("Render password page failed: %s", err)
+	}
+}
+
+// checkLinkPassword Reports whether the password opens the link.
+// Legacy SHA-256 hashes and outdated parameters are replaced by a fresh argon2id hash on success.
+func checkLinkPassword(link *model.Link, password string) bool {
+	ok, rehash := passhash.Verify(link.Password, password, link.ShortHash)
+	if ok && rehash {
+		encoded := passhash.Hash(password)
+		table := db.SetModel(setting.Cfg.DB.Database, "links")
+		if err := table.UpdateByID(link.ShortHash, bson.M{"$set": bson.M{"password": encoded}}); err != nil {
+			log.WarnPrint("Rehash password of %s failed: %s", link.ShortHash, err)
+		} else {
+			link.Password = encoded
+		}
+	}
+	return ok
+}
+
+// validUnlockCookie Reports whether the request carries an unexpired unlock cookie for the link.
+// The signature covers the stored password hash, so changing the password revokes the cookies.
+func validUnlockCookie(c *gin.Context, link model.Link) bool {
+	value, err := c.Cookie(unlockCookieName(link.ShortHash))
+	if err != nil || value == "" {
+		return false
+	}
+	expiresStr, _, found := strings.Cut(value, ".")
+	if !found {
+		return false
+	}
+	expires, err := strconv.ParseInt(expiresStr, 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		return false
+	}
+	return hmac.Equal([]byte(value), []byte(signUnlock(link, expires)))
+}
+
+// signUnlock The unlock cookie value, {expires}.{signature}
+func signUnlock(link model.Link, expires int64) string {
+	expiresStr := strconv.FormatInt(expires, 10)
+	mac := hmac.New(sha256.New, unlockKey)
+	mac.Write([]byte(tool.ConcatStrings("unlock:", link.ShortHash, ":", expiresStr, ":", link.Password)))
+	return tool.ConcatStrings(expiresStr, ".", base64.RawURLEncoding.EncodeToString(mac.Sum(nil)))
+}
+
+func unlockCookieName(hash string) string {
+	return tool.ConcatStrings("lls_unlock_", hash)
+}
+
+// secureCookie Whether cookies get the Secure flag, behind a TLS-terminating proxy the request itself is plain HTTP
+func secureCookie(c *gin.Context) bool {
+	return setting.Cfg.HTTP.SecureCookies || c.Request.TLS != nil
+}
+
+// withoutPasswordQuery The request URI without the legacy pwd parameter, so it does not end up in the browser history
+func withoutPasswordQuery(u *url.URL) string {
+	query := u.Query()
+	if !query.Has("pwd") {
+		return u.RequestURI()
+	}
+	query.Del("pwd")
+	if len(query) == 0 {
+		return u.EscapedPath()
+	}
+	return tool.ConcatStrings(u.EscapedPath(), "?", query.Encode())
+}
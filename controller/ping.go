@@ -3,6 +3,7 @@ package controller
 import (
 	"linkshortener/db"
 	"linkshortener/i18n"
+	"linkshortener/lib/passhash"
 	"linkshortener/lib/tool"
 	"linkshortener/model"
 	"linkshortener/setting"
@@ -33,7 +34,8 @@ func Ping(c *gin.Context) {
 		link.ShortHash = "000000"
 		link.URL = "https://www.example.com/"
 		link.Created = time.Now().Unix()
-		link.Token, _ = tool.GetToken(16)
+		token, _ := tool.GetToken(16)
+		link.Token = passhash.HashToken(token)
 		link.Memo = "Test Hash"
 		link.Delete = false
 		_, err := table.InsertOne(link, false)
@@ -0,0 +1,159 @@
+package controller
+
+import (
+	"context"
+	"html/template"
+	"linkshortener/db"
+	"linkshortener/i18n"
+	"linkshortener/lib/page"
+	"linkshortener/lib/preview"
+	"linkshortener/lib/tool"
+	"linkshortener/log"
+	"linkshortener/model"
+	"linkshortener/setting"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	defaultPreviewFetchTimeout = 3 * time.Second
+	defaultPreviewMaxFetchSize = 512 // KiB
+)
+
+// previewPage The data of the preview template
+type previewPage struct {
+	Lang        string
+	Title       string
+	Description string
+	Image       template.URL
+	URL         string
+}
+
+// isCrawler Reports whether the request comes from a crawler unfurling the link, uap reports them as the Spider device
+func isCrawler(header http.Header, uaInfo model.UAInfo) bool {
+	if strings.EqualFold(uaInfo.Device, "Spider") {
+		return true
+	}
+	userAgent := strings.ToLower(header.Get("User-Agent"))
+	if userAgent == "" {
+		return false
+	}
+	for _, agent := range setting.Cfg.Preview.CrawlerAgents {
+		agent = strings.ToLower(strings.TrimSpace(agent))
+		if agent != "" && strings.Contains(userAgent, agent) {
+			return true
+		}
+	}
+	return false
+}
+
+// renderPreview Show crawlers a page with the Open Graph and Twitter Card tags of the link instead of redirecting
+func renderPreview(c *gin.Context, localizer i18n.ITranslator, link model.Link) {
+	linkPreview := resolvePreview(c.Request.Context(), link)
+	data := previewPage{
+		Lang:        localizer.GetMessage("htmlLang", nil),
+		Title:       linkPreview.Title,
+		Description: linkPreview.Description,
+	}
+	if base := previewBaseURL(); base != "" {
+		data.URL = tool.ConcatStrings(base, c.Request.URL.EscapedPath())
+	}
+	if isHTTPURL(linkPreview.Image) {
+		data.Image = template.URL(linkPreview.Image)
+	}
+	if data.Title == "" {
+		// The memo is stored query escaped by GenerateLink
+		if memo, err := url.QueryUnescape(link.Memo); err == nil && memo != "" {
+			data.Title = memo
+		} else if parsedURL, err := url.Parse(link.URL); err == nil && parsedURL.Host != "" {
+			data.Title = parsedURL.Host
+		} else {
+			data.Title = link.ShortHash
+		}
+	}
+
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Header("Cache-Control", "public, max-age=300")
+	c.Header("Vary", "User-Agent")
+	c.Header("Content-Security-Policy", "default-src 'none'; base-uri 'none'; form-action 'none'; frame-ancestors 'none'")
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Status(http.StatusOK)
+	if err := page.Render(c.Writer, "preview", data); err != nil {
+		log.ErrorPrint("Render preview of %s failed: %s", link.ShortHash, err)
+	}
+}
+
+// previewBaseURL The configured origin of og:url, the Host header is not trusted since crawlers cache the page
+func previewBaseURL() string {
+	base := setting.Cfg.Preview.BaseURL
+	if base == "" {
+		base = setting.Cfg.QR.BaseURL
+	}
+	return strings.TrimSuffix(base, "/")
+}
+
+// resolvePreview The preview stored with the link, missing fields are filled from the metadata of the destination.
+// The destination is fetched once and cached on the link until CACHE_TTL runs out.
+func resolvePreview(ctx context.Context, link model.Link) model.LinkPreview {
+	linkPreview := link.Preview
+	if !setting.Cfg.Preview.FetchPreview || (linkPreview.Title != "" && linkPreview.Description != "" && linkPreview.Image != "") {
+		return linkPreview
+	}
+
+	cached := link.PreviewCache
+	ttl := time.Duration(setting.Cfg.Preview.CacheTTL) * time.Hour
+	if cached.FetchedAt == 0 || (ttl > 0 && time.Since(time.Unix(cached.FetchedAt, 0)) > ttl) {
+		cached = fetchPreview(ctx, link)
+	}
+
+	if linkPreview.Title == "" {
+		linkPreview.Title = cached.Title
+	}
+	if linkPreview.Description == "" {
+		linkPreview.Description = cached.Description
+	}
+	if linkPreview.Image == "" {
+		linkPreview.Image = cached.Image
+	}
+	return linkPreview
+}
+
+// fetchPreview Read the metadata of the link URL and cache it, failures are cached too so the destination is not hammered
+func fetchPreview(ctx context.Context, link model.Link) model.LinkPreview {
+	timeout := time.Duration(setting.Cfg.Preview.FetchTimeout) * time.Second
+	if timeout <= 0 {
+		timeout = defaultPreviewFetchTimeout
+	}
+	maxFetchSize := setting.Cfg.Preview.MaxFetchSize
+	if maxFetchSize <= 0 {
+		maxFetchSize = defaultPreviewMaxFetchSize
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	fetched, err := preview.Fetch(ctx, link.URL, timeout, maxFetchSize*1024)
+	if err != nil {
+		log.DebugPrint("Fetch preview of %s failed: %s", link.ShortHash, err)
+	}
+	fetched.FetchedAt = time.Now().Unix()
+
+	err = db.SetModel(setting.Cfg.DB.Database, "links").UpdateByID(link.ShortHash, bson.M{
+		"$set": bson.M{
+			"preview_cache": bson.M{
+				"title":       fetched.Title,
+				"description": fetched.Description,
+				"image":       fetched.Image,
+				"fetched_at":  fetched.FetchedAt,
+			},
+		},
+	})
+	if err != nil {
+		log.WarnPrint("Cache preview of %s failed: %s", link.ShortHash, err)
+	}
+	return fetched
+}
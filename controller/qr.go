@@ -0,0 +1,161 @@
+package controller
+
+import (
+	"errors"
+	"linkshortener/db"
+	"linkshortener/fs"
+	"linkshortener/i18n"
+	"linkshortener/lib/qr"
+	"linkshortener/lib/tool"
+	"linkshortener/log"
+	"linkshortener/model"
+	"linkshortener/setting"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	defaultQRSize        = 256
+	defaultQRMaxSize     = 1024
+	defaultQRMargin      = 4 // The quiet zone required by the QR code specification
+	defaultQRCacheMaxAge = 3600
+)
+
+// QRCode This method draws the QR code of the full short URL of a link
+// Usage:
+// Send http GET call to
+// {BasePath}/api/qr/:hash?format=svg&size=512&level=H&margin=2&fg=1a1a1a&bg=ffffff&logo=true&caption=true
+// Every parameter is optional, the code is a 256 pixels wide PNG by default.
+func QRCode(c *gin.Context) {
+	var req model.QRCodeReq
+	localizer := i18n.GetLocalizer(c)
+
+	if err := c.ShouldBindUri(&req); err != nil {
+		model.FailureResponse(c, http.StatusBadRequest, http.StatusBadRequest, localizer.GetMessage("deserializationFailed", nil), err.Error())
+		log.ErrorPrint("Deserialization failed: %s", err)
+		return
+	}
+	if err := c.ShouldBindQuery(&req); err != nil {
+		model.FailureResponse(c, http.StatusBadRequest, http.StatusBadRequest, localizer.GetMessage("deserializationFailed", nil), err.Error())
+		log.ErrorPrint("Deserialization failed: %s", err)
+		return
+	}
+
+	opts, err := qrOptions(req)
+	if err != nil {
+		model.FailureResponse(c, http.StatusBadRequest, http.StatusBadRequest, localizer.GetMessage("invalidQRParameter", nil), err.Error())
+		return
+	}
+
+	var res []model.Link
+	table := db.SetModel(setting.Cfg.DB.Database, "links")
+	_ = table.Find(bson.D{{Key: "_id", Value: req.Hash}, {Key: "delete", Value: false}}, &res, db.Find().SetKey(req.Hash))
+
+	if res == nil || len(res) == 0 {
+		model.FailureResponse(c, http.StatusNotFound, http.StatusNotFound, localizer.GetMessage("noLinkFound", nil), "")
+		return
+	}
+	link := res[0]
+	if link.Expire != 0 && time.Now().Unix() > link.Expire {
+		model.FailureResponse(c, http.StatusNotFound, http.StatusNotFound, localizer.GetMessage("linkExpire", nil), "")
+		return
+	}
+	if link.Blocked {
+		model.FailureResponse(c, http.StatusGone, http.StatusGone, localizer.GetMessage("linkBlocked", nil), link.BlockedReason)
+		return
+	}
+
+	shortURL := qrShortURL(c, link.ShortHash)
+	if req.Caption {
+		opts.Caption = strings.TrimPrefix(strings.TrimPrefix(shortURL, "https://"), "http://")
+	}
+
+	var data []byte
+	contentType := "image/png"
+	if req.Format == "svg" {
+		contentType = "image/svg+xml"
+		data, err = qr.SVG(shortURL, opts)
+	} else {
+		data, err = qr.PNG(shortURL, opts)
+	}
+	if err != nil {
+		model.FailureResponse(c, http.StatusInternalServerError, http.StatusInternalServerError, localizer.GetMessage("imageGenerationFailed", nil), "")
+		log.ErrorPrint("QR code generation of %s failed: %s", link.ShortHash, err)
+		return
+	}
+
+	maxAge := setting.Cfg.QR.CacheMaxAge
+	if maxAge <= 0 {
+		maxAge = defaultQRCacheMaxAge
+	}
+	c.Header("Cache-Control", tool.ConcatStrings("public, max-age=", strconv.Itoa(maxAge)))
+	c.Header("Content-Disposition", tool.ConcatStrings(`inline; filename="`, link.ShortHash, ".", tool.If(req.Format == "svg", "svg", "png").(string), `"`))
+	// The SVG may be opened directly, it must not run anything
+	c.Header("Content-Security-Policy", "default-src 'none'; img-src data:; style-src 'unsafe-inline'")
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Data(http.StatusOK, contentType, data)
+}
+
+// qrOptions Fill the drawing options from the query, missing values use the configured defaults
+func qrOptions(req model.QRCodeReq) (qr.Options, error) {
+	opts := qr.Options{
+		Size:   setting.Cfg.QR.DefaultSize,
+		Margin: setting.Cfg.QR.Margin,
+		Font:   fs.CaptchaFont,
+	}
+	if opts.Size <= 0 {
+		opts.Size = defaultQRSize
+	}
+	if opts.Margin <= 0 {
+		opts.Margin = defaultQRMargin
+	}
+	if req.Margin != nil {
+		opts.Margin = *req.Margin
+	}
+
+	maxSize := setting.Cfg.QR.MaxSize
+	if maxSize <= 0 {
+		maxSize = defaultQRMaxSize
+	}
+	if req.Size != 0 {
+		opts.Size = req.Size
+	}
+	if opts.Size > maxSize {
+		return opts, log.Errorf("size is larger than %d", maxSize)
+	}
+
+	var err error
+	if opts.Level, err = qr.ParseLevel(req.Level); err != nil {
+		return opts, err
+	}
+	if opts.Foreground, err = qr.ParseColor(tool.If(req.Foreground == "", "000000", req.Foreground).(string)); err != nil {
+		return opts, log.Errorf("fg: %s", err)
+	}
+	if opts.Background, err = qr.ParseColor(tool.If(req.Background == "", "ffffff", req.Background).(string)); err != nil {
+		return opts, log.Errorf("bg: %s", err)
+	}
+	if err = qr.CheckContrast(opts.Foreground, opts.Background); err != nil {
+		return opts, err
+	}
+
+	if req.Logo {
+		if opts.Logo = qr.Logo(); opts.Logo == nil {
+			return opts, errors.New("no logo is configured")
+		}
+	}
+	return opts, nil
+}
+
+// qrShortURL The full short URL encoded in the code, BASE_URL is used behind proxies that change the host
+func qrShortURL(c *gin.Context, hash string) string {
+	base := strings.TrimSuffix(setting.Cfg.QR.BaseURL, "/")
+	if base == "" {
+		base = tool.ConcatStrings(tool.If(c.Request.TLS != nil, "https", "http").(string), "://", c.Request.Host)
+	}
+	return tool.ConcatStrings(base, setting.Cfg.HTTP.BasePath, "/s/", hash)
+}
@@ -1,17 +1,17 @@
 package controller
 
 import (
-	"crypto/sha256"
-	"encoding/hex"
 	"linkshortener/db"
 	"linkshortener/i18n"
 	"linkshortener/lib/ip2location"
+	"linkshortener/lib/privacy"
 	"linkshortener/lib/tool"
 	"linkshortener/lib/uap"
 	"linkshortener/log"
 	"linkshortener/model"
 	"linkshortener/setting"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -48,9 +48,13 @@ func Redirect(c *gin.Context) {
 	table := db.SetModel(setting.Cfg.DB.Database, "links")
 	_ = table.Find(bson.D{{Key: "_id", Value: req.Hash}, {Key: "delete", Value: false}}, &res, db.Find().SetKey(req.Hash))
 
+	// A trailing path is only accepted by wildcard links
+	if res != nil && len(res) == 1 && !res[0].Wildcard && strings.Trim(req.Path, "/") != "" {
+		res = nil
+	}
+
 	if res != nil && len(res) == 1 {
 		link := res[0]
-		reqPassword := ""
 		if link.Expire != 0 && now > link.Expire {
 			if req.Detect {
 				model.FailureResponse(c, http.StatusNotFound, http.StatusNotFound, localizer.GetMessage("linkExpire", nil), "")
@@ -61,19 +65,62 @@ func Redirect(c *gin.Context) {
 				return
 			}
 		}
+		if link.Blocked {
+			if req.Detect {
+				model.FailureResponse(c, http.StatusGone, http.StatusGone, localizer.GetMessage("linkBlocked", nil), link.BlockedReason)
+			} else {
+				log.DebugPrint("Link Blocked: %s", req.Hash)
+				c.Redirect(http.StatusTemporaryRedirect, tool.ConcatStrings(setting.Cfg.HTTP.SoftRedirectBasePath, "/#/Error/LinkBlocked"))
+			}
+			return
+		}
+		if link.ActivateAt != 0 && now < link.ActivateAt {
+			// The activation time is returned so the countdown page can wait for it
+			c.Header("Retry-After", strconv.FormatInt(link.ActivateAt-now, 10))
+			if req.Detect {
+				model.FailureResponse(c, http.StatusTooEarly, http.StatusTooEarly, localizer.GetMessage("linkNotActive", nil), strconv.FormatInt(link.ActivateAt, 10))
+				return
+			} else if link.Countdown {
+				c.Redirect(http.StatusTemporaryRedirect, tool.ConcatStrings(setting.Cfg.HTTP.SoftRedirectBasePath, "/#/Countdown/", req.Hash))
+				return
+			} else {
+				log.DebugPrint("Link Not Active: %s", req.Hash)
+				c.Redirect(http.StatusTemporaryRedirect, tool.ConcatStrings(setting.Cfg.HTTP.SoftRedirectBasePath, "/#/Error/LinkNotActive"))
+				return
+			}
+		}
+		if link.MaxClicks > 0 && link.Clicks >= link.MaxClicks {
+			linkExhausted(c, localizer, req)
+			return
+		}
 		if link.Password != "" {
-			if req.Password != "" {
-				passwordHash := sha256.Sum256([]byte(tool.ConcatStrings(link.ShortHash, req.Password, tool.Uint32ToBase62String(setting.Cfg.Seed))))
-				reqPassword = hex.EncodeToString(passwordHash[:])
+			// The unlock cookie of the password page and the legacy pwd parameter are both accepted
+			unlocked := validUnlockCookie(c, link)
+			var lockedUntil int64
+			if !unlocked && req.Password != "" {
+				lockedUntil, unlocked = checkPasswordAttempt(c, &link, req.Password)
 			}
-
-			if link.Password != reqPassword {
-				if reqPassword != "" {
+			if !unlocked {
+				if req.Password != "" {
 					log.DebugPrint("password error: %s", req.Hash)
 				}
+				if lockedUntil > 0 {
+					if req.Detect {
+						tooManyAttemptsResponse(c, localizer, lockedUntil)
+					} else if setting.Cfg.Redirect.PasswordPage {
+						c.Header("Retry-After", strconv.FormatInt(max(lockedUntil-now, 1), 10))
+						renderPasswordPage(c, localizer, http.StatusTooManyRequests, localizer.GetMessage("tooManyAttempts", nil))
+					} else {
+						c.Redirect(http.StatusTemporaryRedirect, tool.ConcatStrings(setting.Cfg.HTTP.SoftRedirectBasePath, "/#/Error/TooManyAttempts"))
+					}
+					return
+				}
 				if req.Detect {
 					model.FailureResponse(c, http.StatusUnauthorized, http.StatusUnauthorized, localizer.GetMessage("linkPasswordError", nil), "")
 					return
+				} else if setting.Cfg.Redirect.PasswordPage {
+					renderPasswordPage(c, localizer, http.StatusUnauthorized, tool.If(req.Password != "", localizer.GetMessage("linkPasswordError", nil), "").(string))
+					return
 				} else {
 					c.Redirect(http.StatusTemporaryRedirect, tool.ConcatStrings(setting.Cfg.HTTP.SoftRedirectBasePath, "/#/PasswordRedirect/", req.Hash))
 					return
@@ -83,27 +130,55 @@ func Redirect(c *gin.Context) {
 			if req.Detect {
 				model.FailureResponse(c, http.StatusBadRequest, http.StatusBadRequest, localizer.GetMessage("detectAndSoftMutuallyExclusive", nil), "")
 				return
-			} else {
+			} else if !useInterstitial(link, req, "") {
 				c.Redirect(http.StatusTemporaryRedirect, tool.ConcatStrings(setting.Cfg.HTTP.SoftRedirectBasePath, "/#/SoftRedirect/", req.Hash))
 				return
 			}
 		}
-		go accessLogWorker(c.ClientIP(), req.Hash, c.Request.Header, time.Now().Unix())
+		// Crawlers unfurling the link get its preview, they are neither counted as a click nor logged
+		uaInfo := uap.Parse(c.Request.Header)
+		if !req.Detect && setting.Cfg.Preview.EnablePreview && isCrawler(c.Request.Header, uaInfo) {
+			renderPreview(c, localizer, link)
+			return
+		}
+
+		// Location and UA drive the targeting rules and are reused by the access log
+		location := ip2location.Find(c.ClientIP())
+		destination := resolveDestination(c, link, req, location, uaInfo, time.Unix(now, 0))
+
+		// A click is counted once the destination is revealed, deep links are counted when the soft redirect page detects them
+		interstitial := !req.Detect && useInterstitial(link, req, destination.URL)
+		revealed := req.Detect || interstitial || isHTTPURL(destination.URL)
+		if revealed && !consumeClick(link) {
+			linkExhausted(c, localizer, req)
+			return
+		}
+		if !privacy.OptOut(c.Request.Header) {
+			go accessLogWorker(c.ClientIP(), req.Hash, c.Request.Header.Clone(), location, uaInfo, destination.Variant, time.Now().Unix())
+		}
 		if req.Detect {
-			log.DebugPrint("DetectLink: %s", link.URL)
+			log.DebugPrint("DetectLink: %s", destination.URL)
 			data := map[string]interface{}{
-				"hash":   link.ShortHash,
-				"url":    link.URL,
-				"expire": link.Expire,
-				"memo":   link.Memo,
+				"hash":        link.ShortHash,
+				"url":         destination.URL,
+				"fallback":    destination.Fallback,
+				"variant":     destination.Variant,
+				"expire":      link.Expire,
+				"activate_at": link.ActivateAt,
+				"memo":        link.Memo,
+
+				"redirect_type": redirectStatus(link),
+				"max_clicks":    link.MaxClicks,
 			}
 			model.SuccessResponse(c, data)
 		} else {
-			log.DebugPrint("RedirectLink: %s", link.URL)
-			if !strings.HasPrefix(link.URL, "http://") && !strings.HasPrefix(link.URL, "https://") {
+			log.DebugPrint("RedirectLink: %s", destination.URL)
+			if interstitial {
+				renderInterstitial(c, localizer, link, destination.URL)
+			} else if !isHTTPURL(destination.URL) {
 				c.Redirect(http.StatusTemporaryRedirect, tool.ConcatStrings(setting.Cfg.HTTP.SoftRedirectBasePath, "/#/SoftRedirect/", req.Hash))
 			} else {
-				c.Redirect(http.StatusTemporaryRedirect, link.URL)
+				linkRedirect(c, link, destination.URL)
 			}
 		}
 	} else {
@@ -117,22 +192,129 @@ func Redirect(c *gin.Context) {
 	}
 }
 
-func accessLogWorker(ip string, hash string, header http.Header, nowTime int64) {
-	location := ip2location.Find(ip)
-	uaInfo := uap.Parse(header)
+// consumeClick Count a click of a click-limited link, false means the limit has been reached.
+// The limit is checked by the database together with the increment so concurrent clicks cannot exceed it.
+func consumeClick(link model.Link) bool {
+	if link.MaxClicks <= 0 {
+		return true
+	}
+	table := db.SetModel(setting.Cfg.DB.Database, "links")
+	consumed, err := table.UpdateByIDIf(link.ShortHash,
+		bson.D{{Key: "clicks", Value: bson.M{"$lt": link.MaxClicks}}},
+		bson.M{"$inc": bson.M{"clicks": 1}},
+	)
+	if err != nil {
+		// Fail closed, a one-time link must not be served twice because the counter could not be written
+		log.ErrorPrint("Failed to count the click of %s: %s", link.ShortHash, err)
+		return false
+	}
+	return consumed
+}
+
+// linkExhausted Respond to a click-limited link that has been used up
+func linkExhausted(c *gin.Context, localizer i18n.ITranslator, req model.RedirectLinkReq) {
+	if req.Detect {
+		model.FailureResponse(c, http.StatusNotFound, http.StatusNotFound, localizer.GetMessage("linkExhausted", nil), "")
+		return
+	}
+	log.DebugPrint("Link Exhausted: %s", req.Hash)
+	c.Redirect(http.StatusTemporaryRedirect, tool.ConcatStrings(setting.Cfg.HTTP.SoftRedirectBasePath, "/#/Error/LinkExhausted"))
+}
+
+// redirectStatus The status code used to redirect to the destination of the link, falling back to the server default
+func redirectStatus(link model.Link) int {
+	if IsRedirectType(link.RedirectType) {
+		return link.RedirectType
+	}
+	if IsRedirectType(setting.Cfg.Redirect.DefaultRedirectType) {
+		return setting.Cfg.Redirect.DefaultRedirectType
+	}
+	return http.StatusTemporaryRedirect
+}
+
+// IsRedirectType Reports whether the status code is allowed as the redirect type of a link
+func IsRedirectType(status int) bool {
+	switch status {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	default:
+		return false
+	}
+}
+
+// linkRedirect Redirect to the destination of the link.
+// Browsers cache permanent redirects indefinitely by default, so their lifetime is bounded
+// to keep later edits, deletion and the access log working.
+func linkRedirect(c *gin.Context, link model.Link, destination string) {
+	status := redirectStatus(link)
+	if status == http.StatusMovedPermanently || status == http.StatusPermanentRedirect {
+		if maxAge := setting.Cfg.Redirect.PermanentRedirectMaxAge; maxAge > 0 {
+			c.Header("Cache-Control", tool.ConcatStrings("private, max-age=", strconv.Itoa(maxAge)))
+		} else {
+			c.Header("Cache-Control", "no-cache")
+		}
+	}
+	c.Redirect(status, destination)
+}
 
+func accessLogWorker(ip string, hash string, header http.Header, location model.Location, uaInfo model.UAInfo, variant string, nowTime int64) {
+	// Location and UA were resolved from the raw request, the privacy policy is applied before anything is stored
 	var linkInfo = model.LinkInfo{
 		Hash:     hash,
-		IP:       ip,
-		Header:   header,
+		IP:       privacy.AnonymizeIP(ip),
+		Header:   privacy.FilterHeader(header),
 		Location: location,
 		UAInfo:   uaInfo,
+		Variant:  variant,
 		Created:  nowTime,
 	}
 
 	table := db.SetModel(setting.Cfg.DB.Database, "link_access")
-	_, err := table.InsertOne(linkInfo, true)
+	_, err := table.InsertOne(linkInfo, true, db.Insert().SetTTL(db.AccessLogTTL()).SetKeyPrefix(hash))
 	if err != nil {
 		log.WarnPrint("Failed to write access log to database!")
 	}
+
+	if setting.Cfg.Retention.KeepRollups {
+		accessRollup(linkInfo)
+	}
+
+	publishClick(linkInfo)
+}
+
+// accessRollup Add the access to the daily rollup of the link, which outlives the raw access log
+func accessRollup(linkInfo model.LinkInfo) {
+	day := time.Unix(linkInfo.Created, 0).UTC().Format("20060102")
+	inc := bson.M{
+		"clicks": 1,
+		tool.ConcatStrings("countries.", rollupKey(linkInfo.CountryIsoCode)): 1,
+		tool.ConcatStrings("browsers.", rollupKey(linkInfo.Browser)):         1,
+		tool.ConcatStrings("os.", rollupKey(linkInfo.OS)):                    1,
+		tool.ConcatStrings("devices.", rollupKey(linkInfo.Device)):           1,
+	}
+	if linkInfo.Variant != "" {
+		inc[tool.ConcatStrings("variants.", rollupKey(linkInfo.Variant))] = 1
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"hash": linkInfo.Hash,
+			"day":  day,
+		},
+		"$inc": inc,
+	}
+
+	table := db.SetModel(setting.Cfg.DB.Database, "link_access_rollup")
+	err := table.UpsertByID(tool.ConcatStrings(linkInfo.Hash, ":", day), update)
+	if err != nil {
+		log.WarnPrint("Failed to write access rollup to database!")
+	}
+}
+
+// rollupKey Make the value usable as a document key, dots and leading dollars are not allowed
+func rollupKey(value string) string {
+	value = strings.TrimLeft(strings.ReplaceAll(value, ".", "_"), "$")
+	if value == "" {
+		return "Unknown"
+	}
+	return value
 }
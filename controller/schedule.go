@@ -0,0 +1,28 @@
+package controller
+
+import (
+	"fmt"
+	"linkshortener/lib/schedule"
+	"linkshortener/model"
+	"strings"
+)
+
+// normalizeScheduleRules Check the rules can be evaluated and encode their destinations the same way as the link URL
+func normalizeScheduleRules(rules []model.ScheduleRule) ([]model.ScheduleRule, error) {
+	normalized := make([]model.ScheduleRule, 0, len(rules))
+	for i, rule := range rules {
+		for j, day := range rule.Weekdays {
+			rule.Weekdays[j] = strings.ToLower(day)
+		}
+		if err := schedule.Validate(rule); err != nil {
+			return nil, fmt.Errorf("rule %d: %w", i, err)
+		}
+		destination, err := normalizeDestination(rule.URL)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: %w", i, err)
+		}
+		rule.URL = destination
+		normalized = append(normalized, rule)
+	}
+	return normalized, nil
+}
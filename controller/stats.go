@@ -9,12 +9,17 @@ import (
 	"linkshortener/setting"
 	"math"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
 )
 
+// maxRollupDays The maximum number of daily rollups returned by StatsLink
+const maxRollupDays = 3660
+
 // StatsLink This method provides statistics info for redirections
 // Usage:
 // {BasePath}/api/stats_link
@@ -33,6 +38,11 @@ func StatsLink(c *gin.Context) {
 		return
 	}
 
+	if req.From != 0 && req.To != 0 && req.From > req.To {
+		model.FailureResponse(c, http.StatusBadRequest, http.StatusBadRequest, localizer.GetMessage("invalidFilterParameter", nil), "from is later than to")
+		return
+	}
+
 	// Initialize session object
 	session := sessions.Default(c)
 	sessionCaptcha := tool.SafeSessionGet(session, "captcha")
@@ -49,22 +59,27 @@ func StatsLink(c *gin.Context) {
 	_ = table.Find(bson.D{{Key: "_id", Value: req.Hash}}, &res, db.Find().SetKey(req.Hash))
 
 	if res != nil && len(res) > 0 {
-		if res[0].Token != req.Token {
+		if lockedUntil, ok := checkManageToken(c, res[0], req.Token); !ok {
 			session.Delete("captcha")
 			_ = session.Save()
-			model.FailureResponse(c, http.StatusForbidden, http.StatusForbidden, localizer.GetMessage("passwordVerificationFailed", nil), "")
+			manageTokenFailure(c, localizer, lockedUntil)
 			return
 		}
 
 		var statsRes []model.LinkInfo
 		statsTable := db.SetModel(setting.Cfg.DB.Database, "link_access")
+		statsFilter, appliedFilters := statsLinkFilter(req)
+		findKey := tool.ConcatStrings(req.Hash, ":")
 
 		offset := (req.Page - 1) * req.Size
-		totalCount, _ := statsTable.CountDocuments(bson.D{{Key: "hash", Value: req.Hash}}, db.Find().SetKey(req.Hash))
+		totalCount, _ := statsTable.CountDocuments(statsFilter, db.Find().SetKey(findKey))
 		totalPages := int64(math.Ceil(float64(totalCount) / float64(req.Size)))
 
 		if totalCount > 0 && req.Page <= totalPages {
-			_ = statsTable.Find(bson.D{{Key: "hash", Value: req.Hash}}, &statsRes, db.Find().SetSkip(offset).SetLimit(req.Size).SetKey(req.Hash).SetPrefixScans(true))
+			sortOrder := tool.If(appliedFilters["sort"] == "desc", -1, 1).(int)
+			findOptions := db.Find().SetSkip(offset).SetLimit(req.Size).SetKey(findKey).SetPrefixScans(true).
+				SetSort(bson.D{{Key: "created", Value: sortOrder}}).SetReverse(sortOrder < 0)
+			_ = statsTable.Find(statsFilter, &statsRes, findOptions)
 
 			data := map[string]interface{}{
 				"current": req.Page,
@@ -73,7 +88,11 @@ func StatsLink(c *gin.Context) {
 				"pages":   totalPages,
 				"total":   totalCount,
 				"records": statsRes,
+				"filters": appliedFilters,
 			}
+			statsRetention(req.Hash, data)
+			statsVariants(res[0], statsFilter, findKey, data)
+			statsHealth(res[0], data)
 
 			model.SuccessResponse(c, data)
 		} else {
@@ -83,7 +102,11 @@ func StatsLink(c *gin.Context) {
 				"pages":   0,
 				"total":   0,
 				"records": []string{},
+				"filters": appliedFilters,
 			}
+			statsRetention(req.Hash, data)
+			statsVariants(res[0], statsFilter, findKey, data)
+			statsHealth(res[0], data)
 			model.SuccessResponse(c, data)
 		}
 
@@ -93,3 +116,94 @@ func StatsLink(c *gin.Context) {
 		model.FailureResponse(c, http.StatusNotFound, http.StatusNotFound, localizer.GetMessage("noLinkFound", nil), "")
 	}
 }
+
+// statsHealth Add the result of the last destination health check, null if the link was not checked yet
+func statsHealth(link model.Link, data map[string]interface{}) {
+	if link.Health.CheckedAt == 0 {
+		data["health"] = nil
+		return
+	}
+	data["health"] = link.Health
+}
+
+// statsLinkFilter Build the link_access filter of the request, the second value lists the applied filters for the response
+func statsLinkFilter(req model.ManageLinkReq) (bson.D, map[string]interface{}) {
+	filter := bson.D{{Key: "hash", Value: req.Hash}}
+	applied := map[string]interface{}{
+		"sort": tool.If(req.Sort == "", "asc", req.Sort),
+	}
+
+	created := bson.M{}
+	if req.From != 0 {
+		created["$gte"] = req.From
+		applied["from"] = req.From
+	}
+	if req.To != 0 {
+		created["$lte"] = req.To
+		applied["to"] = req.To
+	}
+	if len(created) > 0 {
+		filter = append(filter, bson.E{Key: "created", Value: created})
+	}
+
+	if req.Country != "" {
+		country := strings.ToUpper(req.Country)
+		filter = append(filter, bson.E{Key: model.LinkInfoCountryIsoCode, Value: country})
+		applied["country"] = country
+	}
+	if req.Browser != "" {
+		filter = append(filter, bson.E{Key: model.LinkInfoBrowser, Value: req.Browser})
+		applied["browser"] = req.Browser
+	}
+	if req.Device != "" {
+		filter = append(filter, bson.E{Key: model.LinkInfoDevice, Value: req.Device})
+		applied["device"] = req.Device
+	}
+	if req.Variant != "" {
+		filter = append(filter, bson.E{Key: "variant", Value: req.Variant})
+		applied["variant"] = req.Variant
+	}
+
+	return filter, applied
+}
+
+// statsVariants Break the matching access records down by the variant served, for links rotating between destinations
+func statsVariants(link model.Link, statsFilter bson.D, findKey string, data map[string]interface{}) {
+	if len(link.Variants) == 0 {
+		return
+	}
+	statsTable := db.SetModel(setting.Cfg.DB.Database, "link_access")
+	breakdown := make([]map[string]interface{}, 0, len(link.Variants))
+	for _, variant := range link.Variants {
+		variantFilter := bson.D{{Key: "variant", Value: variant.Name}}
+		for _, e := range statsFilter {
+			if e.Key != "variant" {
+				variantFilter = append(variantFilter, e)
+			}
+		}
+		clicks, _ := statsTable.CountDocuments(variantFilter, db.Find().SetKey(findKey))
+		breakdown = append(breakdown, map[string]interface{}{
+			"name":   variant.Name,
+			"url":    variant.URL,
+			"weight": variant.Weight,
+			"clicks": clicks,
+		})
+	}
+	data["variants"] = breakdown
+}
+
+// statsRetention Report how far back the access log goes and attach the daily rollups if they are kept
+func statsRetention(hash string, data map[string]interface{}) {
+	data["retention_days"] = setting.Cfg.Retention.AccessLogDays
+	data["retained_since"] = db.AccessLogRetainedSince(time.Now())
+
+	if setting.Cfg.Retention.KeepRollups {
+		var rollupRes []model.LinkAccessRollup
+		rollupTable := db.SetModel(setting.Cfg.DB.Database, "link_access_rollup")
+		_ = rollupTable.Find(bson.D{{Key: "hash", Value: hash}}, &rollupRes, db.Find().SetLimit(maxRollupDays).SetKey(tool.ConcatStrings(hash, ":")).SetPrefixScans(true))
+		if rollupRes == nil {
+			rollupRes = []model.LinkAccessRollup{}
+		}
+		data["rollups"] = rollupRes
+	}
+}
@@ -0,0 +1,138 @@
+package controller
+
+import (
+	"errors"
+	"io"
+	"linkshortener/db"
+	"linkshortener/i18n"
+	"linkshortener/lib/pubsub"
+	"linkshortener/lib/tool"
+	"linkshortener/log"
+	"linkshortener/model"
+	"linkshortener/setting"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// clickHub Distributes the clicks recorded by accessLogWorker to the stream subscribers, keyed by link hash
+var clickHub *pubsub.Hub
+
+// StreamLink This method pushes the clicks of a link as Server-Sent Events while they are recorded
+// Usage:
+// Open an EventSource on
+// {BasePath}/api/stream_link/:hash?captcha=...&token=...
+// The token may also be sent as "Authorization: Bearer {token}" instead of the query string.
+func StreamLink(c *gin.Context) {
+	var req model.StreamLinkReq
+	localizer := i18n.GetLocalizer(c)
+
+	// Binding validates the whole request, so the hash is taken from the path before the query is bound
+	req.Hash = c.Param("hash")
+	if err := c.ShouldBindQuery(&req); err != nil {
+		model.FailureResponse(c, http.StatusBadRequest, http.StatusBadRequest, localizer.GetMessage("deserializationFailed", nil), err.Error())
+		log.ErrorPrint("Deserialization failed: %s", err)
+		return
+	}
+	if bearer := c.GetHeader("Authorization"); strings.HasPrefix(bearer, "Bearer ") {
+		req.Token = strings.TrimSpace(strings.TrimPrefix(bearer, "Bearer "))
+	}
+
+	// Initialize session object
+	session := sessions.Default(c)
+	sessionCaptcha := tool.SafeSessionGet(session, "captcha")
+
+	if sessionCaptcha != req.CAPTCHA {
+		session.Delete("captcha")
+		_ = session.Save()
+		model.FailureResponse(c, http.StatusForbidden, http.StatusForbidden, localizer.GetMessage("captchaVerificationFailed", nil), "")
+		return
+	}
+
+	var res []model.Link
+	table := db.SetModel(setting.Cfg.DB.Database, "links")
+	_ = table.Find(bson.D{{Key: "_id", Value: req.Hash}}, &res, db.Find().SetKey(req.Hash))
+
+	if res == nil || len(res) == 0 {
+		session.Delete("captcha")
+		_ = session.Save()
+		model.FailureResponse(c, http.StatusNotFound, http.StatusNotFound, localizer.GetMessage("noLinkFound", nil), "")
+		return
+	}
+	if lockedUntil, ok := checkManageToken(c, res[0], req.Token); !ok {
+		session.Delete("captcha")
+		_ = session.Save()
+		manageTokenFailure(c, localizer, lockedUntil)
+		return
+	}
+
+	subscriber, err := clickHub.Subscribe(req.Hash)
+	if err != nil {
+		if errors.Is(err, pubsub.ErrTooManySubscribers) {
+			model.FailureResponse(c, http.StatusTooManyRequests, http.StatusTooManyRequests, localizer.GetMessage("tooManySubscribers", nil), "")
+		} else {
+			model.FailureResponse(c, http.StatusInternalServerError, http.StatusInternalServerError, localizer.GetMessage("streamFailed", nil), "")
+		}
+		return
+	}
+	defer clickHub.Unsubscribe(subscriber)
+
+	heartbeat := time.Duration(setting.Cfg.Stream.HeartbeatInterval) * time.Second
+	if heartbeat <= 0 {
+		heartbeat = 15 * time.Second
+	}
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-store")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.SSEvent("ready", gin.H{"hash": req.Hash})
+	c.Writer.Flush()
+
+	log.DebugPrint("Stream subscribed: %s", req.Hash)
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case <-ticker.C:
+			_, _ = w.Write([]byte(": ping\n\n"))
+			return true
+		case msg, ok := <-subscriber.Messages():
+			if !ok {
+				if subscriber.Dropped() {
+					c.SSEvent("dropped", gin.H{"hash": req.Hash})
+					log.DebugPrint("Stream subscriber of %s dropped for falling behind", req.Hash)
+				}
+				return false
+			}
+			c.SSEvent("click", msg)
+			return true
+		}
+	})
+	log.DebugPrint("Stream closed: %s", req.Hash)
+}
+
+// publishClick Push the recorded access to the stream subscribers of the link
+func publishClick(linkInfo model.LinkInfo) {
+	if clickHub == nil || clickHub.Subscribers(linkInfo.Hash) == 0 {
+		return
+	}
+	clickHub.Publish(linkInfo.Hash, model.ClickEvent{
+		Hash:           linkInfo.Hash,
+		CountryIsoCode: linkInfo.CountryIsoCode,
+		Country:        linkInfo.Country,
+		Device:         linkInfo.Device,
+		Browser:        linkInfo.Browser,
+		OS:             linkInfo.OS,
+		Referrer:       linkInfo.Header.Get("Referer"),
+		Variant:        linkInfo.Variant,
+		Created:        linkInfo.Created,
+	})
+}
@@ -0,0 +1,76 @@
+package controller
+
+import (
+	"linkshortener/db"
+	"linkshortener/i18n"
+	"linkshortener/lib/passhash"
+	"linkshortener/lib/tool"
+	"linkshortener/log"
+	"linkshortener/model"
+	"linkshortener/setting"
+	"net/http"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// RotateToken This method replaces the manage token of a link, the new token is only returned once
+// Usage:
+// Send http POST call to
+// {BasePath}/api/rotate_token
+func RotateToken(c *gin.Context) {
+	var req model.ManageLinkReq
+	localizer := i18n.GetLocalizer(c)
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		model.FailureResponse(c, http.StatusBadRequest, http.StatusBadRequest, localizer.GetMessage("deserializationFailed", nil), err.Error())
+		log.ErrorPrint("Deserialization failed: %s", err)
+		return
+	}
+
+	// Initialize session object
+	session := sessions.Default(c)
+	sessionCaptcha := tool.SafeSessionGet(session, "captcha")
+	session.Delete("captcha")
+	_ = session.Save()
+
+	if sessionCaptcha != req.CAPTCHA {
+		model.FailureResponse(c, http.StatusForbidden, http.StatusForbidden, localizer.GetMessage("captchaVerificationFailed", nil), "")
+		return
+	}
+
+	var res []model.Link
+	table := db.SetModel(setting.Cfg.DB.Database, "links")
+	_ = table.Find(bson.D{{Key: "_id", Value: req.Hash}, {Key: "delete", Value: false}}, &res, db.Find().SetKey(req.Hash))
+
+	if res == nil || len(res) == 0 {
+		model.FailureResponse(c, http.StatusNotFound, http.StatusNotFound, localizer.GetMessage("noLinkFound", nil), "")
+		return
+	}
+	if lockedUntil, ok := checkManageToken(c, res[0], req.Token); !ok {
+		manageTokenFailure(c, localizer, lockedUntil)
+		return
+	}
+
+	// The update only applies if the token was not rotated by a concurrent request in the meantime
+	token, _ := tool.GetToken(16)
+	ok, err := table.UpdateByIDIf(req.Hash,
+		bson.D{{Key: "token", Value: res[0].Token}},
+		bson.M{"$set": bson.M{"token": passhash.HashToken(token)}},
+	)
+	if err != nil {
+		model.FailureResponse(c, http.StatusInternalServerError, http.StatusInternalServerError, localizer.GetMessage("databaseOperationFailed", nil), "")
+		log.ErrorPrint("Rotate token of %s failed: %s", req.Hash, err)
+		return
+	}
+	if !ok {
+		model.FailureResponse(c, http.StatusConflict, http.StatusConflict, localizer.GetMessage("passwordVerificationFailed", nil), "")
+		return
+	}
+
+	model.SuccessResponse(c, map[string]interface{}{
+		"hash":  req.Hash,
+		"token": token,
+	})
+}
@@ -0,0 +1,90 @@
+package controller
+
+import (
+	"fmt"
+	"linkshortener/lib/tool"
+	"linkshortener/model"
+	"linkshortener/setting"
+	"math/rand/v2"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultStickyVariantMaxAge Seconds a visitor keeps the variant when STICKY_VARIANT_MAX_AGE is not set
+const defaultStickyVariantMaxAge = 30 * 24 * 3600
+
+// normalizeVariants Check the variant names are unique and encode their destinations the same way as the link URL
+func normalizeVariants(variants []model.Variant) ([]model.Variant, error) {
+	names := make(map[string]struct{}, len(variants))
+	normalized := make([]model.Variant, 0, len(variants))
+	for i, variant := range variants {
+		if _, exists := names[variant.Name]; exists {
+			return nil, fmt.Errorf("variant %d: duplicate name %s", i, variant.Name)
+		}
+		names[variant.Name] = struct{}{}
+
+		destination, err := normalizeDestination(variant.URL)
+		if err != nil {
+			return nil, fmt.Errorf("variant %d: %w", i, err)
+		}
+		variant.URL = destination
+		normalized = append(normalized, variant)
+	}
+	return normalized, nil
+}
+
+// chooseVariant Pick the variant served to the visitor by weight, ok is false when the link has no variants.
+// Sticky links keep serving the variant remembered in the cookie as long as it still exists.
+func chooseVariant(c *gin.Context, link model.Link) (variant model.Variant, ok bool) {
+	if len(link.Variants) == 0 {
+		return model.Variant{}, false
+	}
+
+	cookieName := variantCookieName(link.ShortHash)
+	if link.StickyVariant {
+		if name, err := c.Cookie(cookieName); err == nil {
+			for _, variant = range link.Variants {
+				if variant.Name == name {
+					return variant, true
+				}
+			}
+		}
+	}
+
+	variant = pickVariant(link.Variants, rand.IntN)
+
+	if link.StickyVariant {
+		maxAge := setting.Cfg.Redirect.StickyVariantMaxAge
+		if maxAge <= 0 {
+			maxAge = defaultStickyVariantMaxAge
+		}
+		c.SetSameSite(http.SameSiteLaxMode)
+		c.SetCookie(cookieName, variant.Name, maxAge, tool.ConcatStrings(setting.Cfg.HTTP.BasePath, "/s/", link.ShortHash), "", secureCookie(c), true)
+	}
+	return variant, true
+}
+
+// pickVariant Weighted random choice, intN returns a number in [0, n)
+func pickVariant(variants []model.Variant, intN func(n int) int) model.Variant {
+	total := 0
+	for _, variant := range variants {
+		total += max(variant.Weight, 0)
+	}
+	if total == 0 {
+		return variants[intN(len(variants))]
+	}
+
+	point := intN(total)
+	for _, variant := range variants {
+		point -= max(variant.Weight, 0)
+		if point < 0 {
+			return variant
+		}
+	}
+	return variants[len(variants)-1]
+}
+
+func variantCookieName(hash string) string {
+	return tool.ConcatStrings("lls_variant_", hash)
+}
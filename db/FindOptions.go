@@ -20,6 +20,13 @@ type FindOptions struct {
 	Key string
 
 	PrefixScans bool
+
+	// The order in which to return documents, e.g. bson.D{{Key: "created", Value: -1}}, MongoDB only.
+	Sort interface{}
+
+	// Iterate the key prefix in descending key order, BadgerDB only. It is the BadgerDB counterpart of Sort for
+	// documents whose keys are generated in insertion order.
+	Reverse bool
 }
 
 func Find() *FindOptions {
@@ -61,3 +68,15 @@ func (f *FindOptions) SetPrefixScans(i bool) *FindOptions {
 	f.PrefixScans = i
 	return f
 }
+
+// SetSort sets the value for the Sort field.
+func (f *FindOptions) SetSort(sort interface{}) *FindOptions {
+	f.Sort = sort
+	return f
+}
+
+// SetReverse sets the value for the Reverse field.
+func (f *FindOptions) SetReverse(i bool) *FindOptions {
+	f.Reverse = i
+	return f
+}
@@ -0,0 +1,59 @@
+package db
+
+import "time"
+
+type InsertOptions struct {
+	// The time after which the document is removed by the database. The default value is 0, which means that the
+	// document never expires. BadgerDB uses the entry TTL, MongoDB stores an "expire_at" date for the TTL index.
+	TTL time.Duration
+
+	// The prefix of the generated key when autoKey is true, BadgerDB only. Documents sharing a prefix can be
+	// found with FindOptions.PrefixScans, the rest of the key keeps them in insertion order.
+	KeyPrefix string
+
+	// The insert fails with ErrDuplicateKey when a document with the same _id exists instead of replacing it.
+	// MongoDB always fails this way, BadgerDB overwrites the document unless this is set.
+	FailIfExists bool
+}
+
+func Insert() *InsertOptions {
+	return &InsertOptions{}
+}
+
+// SetTTL sets the value for the TTL field.
+func (i *InsertOptions) SetTTL(ttl time.Duration) *InsertOptions {
+	i.TTL = ttl
+	return i
+}
+
+// SetKeyPrefix sets the value for the KeyPrefix field.
+func (i *InsertOptions) SetKeyPrefix(prefix string) *InsertOptions {
+	i.KeyPrefix = prefix
+	return i
+}
+
+// SetFailIfExists sets the value for the FailIfExists field.
+func (i *InsertOptions) SetFailIfExists(failIfExists bool) *InsertOptions {
+	i.FailIfExists = failIfExists
+	return i
+}
+
+// mergeInsertOptions combines the optional InsertOptions into one.
+func mergeInsertOptions(opts ...*InsertOptions) *InsertOptions {
+	merged := Insert()
+	for _, opt := range opts {
+		if opt == nil {
+			continue
+		}
+		if opt.TTL > 0 {
+			merged.TTL = opt.TTL
+		}
+		if opt.KeyPrefix != "" {
+			merged.KeyPrefix = opt.KeyPrefix
+		}
+		if opt.FailIfExists {
+			merged.FailIfExists = true
+		}
+	}
+	return merged
+}
@@ -6,7 +6,6 @@ import (
 	"linkshortener/lib/tool"
 	"linkshortener/log"
 	"linkshortener/setting"
-	"strconv"
 	"time"
 
 	"github.com/dgraph-io/badger/v4"
@@ -72,8 +71,9 @@ func (b *BadgerDBTable) getDB() *badger.DB {
 	return b.db.BadgerDB
 }
 
-func (b *BadgerDBTable) InsertOne(document interface{}, autoKey bool) (interface{}, error) {
+func (b *BadgerDBTable) InsertOne(document interface{}, autoKey bool, opts ...*InsertOptions) (interface{}, error) {
 	var key string
+	opt := mergeInsertOptions(opts...)
 	db := b.getDB()
 	doc := make(map[string]interface{})
 	val, err := tool.MarshalJsonByBson(document)
@@ -87,7 +87,11 @@ func (b *BadgerDBTable) InsertOne(document interface{}, autoKey bool) (interface
 		if ok && fmt.Sprint(id) != "" {
 			return nil, log.Errorf("_id should not be provided when autoKey is true")
 		}
-		key = tool.ConcatStrings(time.Now().Format("20060102150405"), ":", strconv.FormatUint(tool.GlobalCounterSafeAdd(1), 16))
+		// The counter is zero padded so that keys created in the same second keep their insertion order
+		key = tool.ConcatStrings(time.Now().Format("20060102150405"), ":", fmt.Sprintf("%016x", tool.GlobalCounterSafeAdd(1)))
+		if opt.KeyPrefix != "" {
+			key = tool.ConcatStrings(opt.KeyPrefix, ":", key)
+		}
 		doc["_id"] = key
 		val, _ = json.Marshal(doc)
 	} else {
@@ -99,9 +103,25 @@ func (b *BadgerDBTable) InsertOne(document interface{}, autoKey bool) (interface
 	}
 
 	dbErr := db.Update(func(txn *badger.Txn) error {
-		return txn.Set([]byte(tool.ConcatStrings(b.tableName, ":", key)), val)
+		entryKey := []byte(tool.ConcatStrings(b.tableName, ":", key))
+		if opt.FailIfExists {
+			if _, err := txn.Get(entryKey); err == nil {
+				return ErrDuplicateKey
+			} else if !errors.Is(err, badger.ErrKeyNotFound) {
+				return err
+			}
+		}
+		entry := badger.NewEntry(entryKey, val)
+		if opt.TTL > 0 {
+			entry = entry.WithTTL(opt.TTL)
+		}
+		return txn.SetEntry(entry)
 	})
-	if dbErr != nil {
+	// A concurrent insert of the same key makes the commit conflict
+	if opt.FailIfExists && errors.Is(dbErr, badger.ErrConflict) {
+		dbErr = ErrDuplicateKey
+	}
+	if dbErr != nil && !errors.Is(dbErr, ErrDuplicateKey) {
 		log.ErrorPrint("InsertOne Update document error: %v", dbErr)
 	}
 	return key, dbErr
@@ -113,64 +133,129 @@ func (b *BadgerDBTable) UpdateOne(filter interface{}, result interface{}) error
 }
 
 func (b *BadgerDBTable) UpdateByID(id string, update interface{}) error {
+	_, err := b.updateByID(id, nil, update, false)
+	return err
+}
+
+func (b *BadgerDBTable) UpsertByID(id string, update interface{}) error {
+	_, err := b.updateByID(id, nil, update, true)
+	return err
+}
+
+// badgerConflictRetries The number of times a conditional update is retried after a transaction conflict
+const badgerConflictRetries = 16
+
+// UpdateByIDIf applies the update only if the document also matches filter, the check and the update run in one transaction.
+// It reports whether the document was updated.
+func (b *BadgerDBTable) UpdateByIDIf(id string, filter interface{}, update interface{}) (bool, error) {
+	if filter == nil {
+		return false, log.Errorf("filter cannot be nil")
+	}
+	// Concurrent transactions writing the same key fail on commit, the loser retries against the new value
+	for attempt := 0; ; attempt++ {
+		updated, err := b.updateByID(id, filter, update, false)
+		if errors.Is(err, badger.ErrConflict) && attempt < badgerConflictRetries {
+			continue
+		}
+		return updated, err
+	}
+}
+
+// updateByID applies the $set and $inc operators of update to the document if it matches filter (nil matches any),
+// if upsert is true a missing document is created
+func (b *BadgerDBTable) updateByID(id string, filter interface{}, update interface{}, upsert bool) (bool, error) {
 	if update == nil {
-		return log.Errorf("update cannot be nil")
+		return false, log.Errorf("update cannot be nil")
+	}
+	var matchFilter map[string]interface{}
+	if filter != nil {
+		var err error
+		if matchFilter, err = badgerFilter(filter); err != nil {
+			return false, err
+		}
 	}
-	var updateData map[string]interface{}
+	var setData, incData map[string]interface{}
 	updateDataBson, ok := update.(bson.M)
 
 	if ok {
 		updateDataBsonMap := map[string]interface{}(updateDataBson)
-		updateDataBsonMapSet, keyExists := updateDataBsonMap["$set"]
-		if !keyExists {
-			return log.Errorf("update requires $set")
+		updateDataBsonMapSet, setExists := updateDataBsonMap["$set"]
+		updateDataBsonMapInc, incExists := updateDataBsonMap["$inc"]
+		if !setExists && !incExists {
+			return false, log.Errorf("update requires $set or $inc")
 		}
-		updateDataBsonMapSetMap, updateDataBsonMapSetOk := updateDataBsonMapSet.(bson.M)
-		if updateDataBsonMapSetOk {
-			updateData = updateDataBsonMapSetMap
-		} else {
-			return log.Errorf("update.$set needs to be of type bson.M")
+		if setExists {
+			updateDataBsonMapSetMap, updateDataBsonMapSetOk := updateDataBsonMapSet.(bson.M)
+			if !updateDataBsonMapSetOk {
+				return false, log.Errorf("update.$set needs to be of type bson.M")
+			}
+			setData = updateDataBsonMapSetMap
+		}
+		if incExists {
+			updateDataBsonMapIncMap, updateDataBsonMapIncOk := updateDataBsonMapInc.(bson.M)
+			if !updateDataBsonMapIncOk {
+				return false, log.Errorf("update.$inc needs to be of type bson.M")
+			}
+			incData = updateDataBsonMapIncMap
 		}
 	} else {
-		return log.Errorf("update needs to be of type bson.M")
+		return false, log.Errorf("update needs to be of type bson.M")
 	}
 
 	db := b.getDB()
 	key := tool.ConcatStrings(b.tableName, ":", id)
+	updated := false
 
 	err := db.Update(func(txn *badger.Txn) error {
+		mMap := make(map[string]interface{})
+		var expiresAt uint64
 		item, err := txn.Get([]byte(key))
 		if err != nil {
-			return err
+			if !upsert || !errors.Is(err, badger.ErrKeyNotFound) {
+				return err
+			}
+			mMap["_id"] = id
+		} else {
+			expiresAt = item.ExpiresAt()
+			err = item.Value(func(val []byte) error {
+				return json.Unmarshal(val, &mMap)
+			})
+			if err != nil {
+				return log.Errorf("BadgerDB Value Read Error: %s", err)
+			}
 		}
-		mMap := make(map[string]interface{})
-		err = item.Value(func(val []byte) error {
-			return json.Unmarshal(val, &mMap)
-		})
-		if err != nil {
-			return log.Errorf("BadgerDB Value Read Error: %s", err)
+		if matchFilter != nil && !tool.IsDataMatchingFilter(mMap, matchFilter) {
+			return nil
 		}
 
-		for updateKey, updateValue := range updateData {
-			_, keyExists := mMap[updateKey]
-			if !keyExists {
-				return log.Errorf("the Key of update does not exist in the data")
-			}
+		// Like in MongoDB $set adds the fields the document does not have yet
+		for updateKey, updateValue := range setData {
 			mMap[updateKey] = updateValue
 		}
 
+		for updateKey, updateValue := range incData {
+			err = tool.IncMapPath(mMap, updateKey, updateValue)
+			if err != nil {
+				return log.Errorf("BadgerDB Inc Error: %s", err)
+			}
+		}
+
 		newValueBytes, err := json.Marshal(mMap)
 		if err != nil {
 			return log.Errorf("MarshalJsonByBson Error: %s", err)
 		}
-		err = txn.Set([]byte(key), newValueBytes)
+		// The document keeps the expiry it was inserted with
+		entry := badger.NewEntry([]byte(key), newValueBytes)
+		entry.ExpiresAt = expiresAt
+		err = txn.SetEntry(entry)
 		if err != nil {
 			return log.Errorf("BadgerDB Set Error: %s", err)
 		}
+		updated = true
 		return nil
 	})
 
-	return err
+	return updated, err
 }
 
 func (b *BadgerDBTable) FindByID(id interface{}, result interface{}) error {
@@ -212,19 +297,9 @@ func (b *BadgerDBTable) FindOne(filter interface{}, result interface{}) error {
 }
 
 func (b *BadgerDBTable) Find(filter interface{}, result interface{}, opt *FindOptions) error {
-	findFilter := make(map[string]interface{})
-	if filter != nil {
-		switch f := filter.(type) {
-		case bson.D:
-			findFilter = make(map[string]interface{}, len(f))
-			for _, elem := range f {
-				findFilter[elem.Key] = elem.Value
-			}
-		case bson.M:
-			findFilter = f
-		default:
-			return log.Errorf("filter must be of type bson.D or bson.M")
-		}
+	findFilter, err := badgerFilter(filter)
+	if err != nil {
+		return err
 	}
 
 	if opt.Key == "" {
@@ -237,16 +312,14 @@ func (b *BadgerDBTable) Find(filter interface{}, result interface{}, opt *FindOp
 
 	if opt.PrefixScans {
 		err := db.View(func(txn *badger.Txn) error {
-			opts := badger.DefaultIteratorOptions
-			opts.Prefix = []byte(key)
-			it := txn.NewIterator(opts)
+			it := newPrefixIterator(txn, []byte(key), opt.Reverse, true)
 			defer it.Close()
 
-			index := 0
-			for it.Rewind(); it.Valid() && index < int(opt.Skip); it.Next() {
-				index++
-			}
-			for index < int(opt.Skip+opt.Limit) && it.Valid() {
+			var matched int64
+			for it.Rewind(); it.Valid(); it.Next() {
+				if opt.Limit > 0 && matched >= opt.Skip+opt.Limit {
+					break
+				}
 				item := it.Item()
 				mMap := make(map[string]interface{})
 				err := item.Value(func(val []byte) error {
@@ -257,11 +330,11 @@ func (b *BadgerDBTable) Find(filter interface{}, result interface{}, opt *FindOp
 				}
 
 				if findFilter == nil || tool.IsDataMatchingFilter(mMap, findFilter) {
-					mSlice = append(mSlice, mMap)
+					if matched >= opt.Skip {
+						mSlice = append(mSlice, mMap)
+					}
+					matched++
 				}
-
-				it.Next()
-				index++
 			}
 
 			mSliceJson, _ := json.Marshal(mSlice)
@@ -297,12 +370,63 @@ func (b *BadgerDBTable) Find(filter interface{}, result interface{}, opt *FindOp
 	}
 }
 
+// FindEach streams the matching documents under the key prefix to fn one at a time instead of loading them all into memory.
+// decode unmarshals the current document into result, iteration stops at the first error returned by fn.
+// Like DeleteMany an empty Key scans the whole table.
+func (b *BadgerDBTable) FindEach(filter interface{}, opt *FindOptions, fn func(decode func(result interface{}) error) error) error {
+	findFilter, err := badgerFilter(filter)
+	if err != nil {
+		return err
+	}
+	db := b.getDB()
+	key := tool.ConcatStrings(b.tableName, ":", opt.Key)
+
+	return db.View(func(txn *badger.Txn) error {
+		it := newPrefixIterator(txn, []byte(key), opt.Reverse, true)
+		defer it.Close()
+
+		var matched int64
+		for it.Rewind(); it.Valid(); it.Next() {
+			if opt.Limit > 0 && matched >= opt.Skip+opt.Limit {
+				break
+			}
+			val, err := it.Item().ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			mMap := make(map[string]interface{})
+			if err = json.Unmarshal(val, &mMap); err != nil {
+				return err
+			}
+			if !tool.IsDataMatchingFilter(mMap, findFilter) {
+				continue
+			}
+			matched++
+			if matched <= opt.Skip {
+				continue
+			}
+
+			err = fn(func(result interface{}) error {
+				return tool.UnmarshalJsonByBson(val, result)
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
 func (b *BadgerDBTable) CreateOneIndex(interface{}, ...interface{}) error {
 	return nil
 }
 
-func (b *BadgerDBTable) CountDocuments(_ interface{}, opt *FindOptions) (int64, error) {
+func (b *BadgerDBTable) CountDocuments(filter interface{}, opt *FindOptions) (int64, error) {
 	var count int64 = 0
+	findFilter, err := badgerFilter(filter)
+	if err != nil {
+		return count, err
+	}
 	if opt.Key == "" {
 		log.ErrorPrint("BadgerDB requires Key")
 		return count, fmt.Errorf("BadgerDB requires Key")
@@ -310,14 +434,23 @@ func (b *BadgerDBTable) CountDocuments(_ interface{}, opt *FindOptions) (int64,
 	db := b.getDB()
 	key := tool.ConcatStrings(b.tableName, ":", opt.Key)
 
-	err := db.View(func(txn *badger.Txn) error {
-		opts := badger.DefaultIteratorOptions
-		opts.PrefetchValues = false
-		opts.Prefix = []byte(key)
-		it := txn.NewIterator(opts)
+	err = db.View(func(txn *badger.Txn) error {
+		it := newPrefixIterator(txn, []byte(key), false, len(findFilter) > 0)
 		defer it.Close()
 
-		for it.Seek([]byte(key)); it.ValidForPrefix([]byte(key)); it.Next() {
+		for it.Rewind(); it.Valid(); it.Next() {
+			if len(findFilter) > 0 {
+				mMap := make(map[string]interface{})
+				err := it.Item().Value(func(val []byte) error {
+					return json.Unmarshal(val, &mMap)
+				})
+				if err != nil {
+					return err
+				}
+				if !tool.IsDataMatchingFilter(mMap, findFilter) {
+					continue
+				}
+			}
 			count++
 		}
 		return nil
@@ -325,3 +458,111 @@ func (b *BadgerDBTable) CountDocuments(_ interface{}, opt *FindOptions) (int64,
 
 	return count, err
 }
+
+// prefixIterator iterates over the keys starting with prefix, in reverse key order if reverse is true
+type prefixIterator struct {
+	*badger.Iterator
+	prefix  []byte
+	reverse bool
+}
+
+func newPrefixIterator(txn *badger.Txn, prefix []byte, reverse bool, prefetchValues bool) *prefixIterator {
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = prefix
+	opts.Reverse = reverse
+	opts.PrefetchValues = prefetchValues
+	return &prefixIterator{
+		Iterator: txn.NewIterator(opts),
+		prefix:   prefix,
+		reverse:  reverse,
+	}
+}
+
+// Rewind positions the iterator on the first key of the prefix in iteration order
+func (p *prefixIterator) Rewind() {
+	if p.reverse {
+		// In reverse mode Seek finds the largest key <= the given key, so seek past every key of the prefix
+		p.Seek(append(append([]byte{}, p.prefix...), 0xFF))
+		return
+	}
+	p.Seek(p.prefix)
+}
+
+// Valid returns false once the iterator leaves the prefix
+func (p *prefixIterator) Valid() bool {
+	return p.ValidForPrefix(p.prefix)
+}
+
+// DeleteMany removes the documents under the key prefix that match the filter.
+// Unlike Find an empty Key is allowed and scans the whole table.
+func (b *BadgerDBTable) DeleteMany(filter interface{}, opt *FindOptions) (int64, error) {
+	findFilter, err := badgerFilter(filter)
+	if err != nil {
+		return 0, err
+	}
+
+	db := b.getDB()
+	prefix := []byte(tool.ConcatStrings(b.tableName, ":", opt.Key))
+	var keys [][]byte
+
+	err = db.View(func(txn *badger.Txn) error {
+		it := newPrefixIterator(txn, prefix, false, len(findFilter) > 0)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			item := it.Item()
+			if len(findFilter) > 0 {
+				mMap := make(map[string]interface{})
+				err := item.Value(func(val []byte) error {
+					return json.Unmarshal(val, &mMap)
+				})
+				if err != nil {
+					return err
+				}
+				if !tool.IsDataMatchingFilter(mMap, findFilter) {
+					continue
+				}
+			}
+			keys = append(keys, item.KeyCopy(nil))
+		}
+		return nil
+	})
+	if err != nil {
+		log.ErrorPrint("BadgerDB DeleteMany Scan Error: %s", err)
+		return 0, err
+	}
+
+	wb := db.NewWriteBatch()
+	defer wb.Cancel()
+	for _, key := range keys {
+		if err = wb.Delete(key); err != nil {
+			log.ErrorPrint("BadgerDB DeleteMany Error: %s", err)
+			return 0, err
+		}
+	}
+	if err = wb.Flush(); err != nil {
+		log.ErrorPrint("BadgerDB DeleteMany Flush Error: %s", err)
+		return 0, err
+	}
+
+	return int64(len(keys)), nil
+}
+
+// badgerFilter converts a bson filter into the map used by tool.IsDataMatchingFilter
+func badgerFilter(filter interface{}) (map[string]interface{}, error) {
+	findFilter := make(map[string]interface{})
+	if filter != nil {
+		switch f := filter.(type) {
+		case bson.D:
+			findFilter = make(map[string]interface{}, len(f))
+			for _, elem := range f {
+				findFilter[elem.Key] = elem.Value
+			}
+		case bson.M:
+			findFilter = f
+		default:
+			return nil, log.Errorf("filter must be of type bson.D or bson.M")
+		}
+	}
+	return findFilter, nil
+}
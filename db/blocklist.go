@@ -0,0 +1,59 @@
+package db
+
+import (
+	"linkshortener/lib/blocklist"
+	"linkshortener/log"
+	"linkshortener/model"
+	"linkshortener/setting"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// RecheckLinks Disable the links whose destinations were listed by the threat feeds after they were created.
+// The reason is recorded on the link, links are not enabled again when they drop off a feed.
+func RecheckLinks() {
+	if !setting.Cfg.Blocklist.RecheckLinks {
+		return
+	}
+	type listedLink struct {
+		hash   string
+		reason string
+	}
+	var listed []listedLink
+
+	table := SetModel(setting.Cfg.DB.Database, "links")
+	err := table.FindEach(bson.D{{Key: "delete", Value: false}}, Find(), func(decode func(result interface{}) error) error {
+		var link model.Link
+		if err := decode(&link); err != nil {
+			return err
+		}
+		if link.Blocked {
+			return nil
+		}
+		if reason, ok := blocklist.CheckLink(link); ok {
+			listed = append(listed, listedLink{hash: link.ShortHash, reason: reason})
+		}
+		return nil
+	})
+	if err != nil {
+		log.ErrorPrint("Threat feed recheck scan failed: %s", err)
+		return
+	}
+
+	now := time.Now().Unix()
+	for _, link := range listed {
+		err = table.UpdateByID(link.hash, bson.M{
+			"$set": bson.M{
+				"blocked":        true,
+				"blocked_reason": link.reason,
+				"blocked_at":     now,
+			},
+		})
+		if err != nil {
+			log.WarnPrint("Disable listed link %s failed: %s", link.hash, err)
+			continue
+		}
+		log.WarnPrint("Link %s disabled: %s", link.hash, link.reason)
+	}
+}
@@ -0,0 +1,86 @@
+package db
+
+import (
+	"context"
+	"linkshortener/lib/healthcheck"
+	"linkshortener/log"
+	"linkshortener/model"
+	"linkshortener/setting"
+	"sync/atomic"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// CheckLinkHealth Check the URL of every active link and record the result on the link
+func CheckLinkHealth(ctx context.Context, checker *healthcheck.Checker) (int, error) {
+	now := time.Now().Unix()
+	var targets []healthcheck.Target
+
+	table := SetModel(setting.Cfg.DB.Database, "links")
+	err := table.FindEach(bson.D{{Key: "delete", Value: false}}, Find(), func(decode func(result interface{}) error) error {
+		var link model.Link
+		if err := decode(&link); err != nil {
+			return err
+		}
+		if link.Blocked || (link.Expire != 0 && now > link.Expire) || (link.MaxClicks > 0 && link.Clicks >= link.MaxClicks) {
+			return nil
+		}
+		targets = append(targets, healthcheck.Target{Hash: link.ShortHash, URL: link.URL})
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	var failed atomic.Int64
+	checker.Run(ctx, targets, func(target healthcheck.Target, health model.LinkHealth) {
+		if !health.Healthy {
+			failed.Add(1)
+			log.DebugPrint("Health check of %s failed: status %d %s", target.Hash, health.Status, health.Error)
+		}
+		err := table.UpdateByID(target.Hash, bson.M{
+			"$set": bson.M{
+				"health": bson.M{
+					"healthy":    health.Healthy,
+					"status":     health.Status,
+					"latency_ms": health.LatencyMs,
+					"checked_at": health.CheckedAt,
+					"error":      health.Error,
+				},
+			},
+		})
+		if err != nil {
+			log.WarnPrint("Record health of %s failed: %s", target.Hash, err)
+		}
+	})
+	return len(targets) - int(failed.Load()), nil
+}
+
+// InitHealthCheck Start the periodic destination health check if it is enabled
+func InitHealthCheck() {
+	cfg := setting.Cfg.HealthCheck
+	if !cfg.EnableHealthCheck {
+		return
+	}
+	interval := time.Duration(cfg.Interval) * time.Minute
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	checker := healthcheck.NewChecker(cfg.Concurrency, cfg.PerHostRate, time.Duration(cfg.Timeout)*time.Second)
+	log.InfoPrint("Destination health check every %v", interval)
+
+	go func() {
+		for {
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			healthy, err := CheckLinkHealth(ctx, checker)
+			cancel()
+			if err != nil {
+				log.WarnPrint("Destination health check failed: %s", err)
+			} else {
+				log.DebugPrint("Destination health check finished, %d healthy", healthy)
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
@@ -0,0 +1,77 @@
+package db
+
+import (
+	"errors"
+	"linkshortener/model"
+	"linkshortener/setting"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	defaultIdempotencyTTL = 24 * time.Hour
+	// IdempotencyLease How long a request may hold its key without completing before a retry takes the key over
+	IdempotencyLease = 5 * time.Minute
+)
+
+// IdempotencyTTL How long an Idempotency-Key is remembered, IDEMPOTENCY_TTL is in hours
+func IdempotencyTTL() time.Duration {
+	if setting.Cfg.Bulk.IdempotencyTTL <= 0 {
+		return defaultIdempotencyTTL
+	}
+	return time.Duration(setting.Cfg.Bulk.IdempotencyTTL) * time.Hour
+}
+
+// ClaimIdempotencyKey Record that a request with the key is being processed.
+// claimed is false when the key was already used, record is then the earlier request.
+// A claim that was not completed within IdempotencyLease is taken over, its request is assumed to have died.
+func ClaimIdempotencyKey(id string, requestHash string, now time.Time) (record model.IdempotencyRecord, claimed bool, err error) {
+	table := SetModel(setting.Cfg.DB.Database, "idempotency_keys")
+	record = model.IdempotencyRecord{
+		ID:          id,
+		RequestHash: requestHash,
+		Created:     now.Unix(),
+	}
+	_, err = table.InsertOne(record, false, Insert().SetTTL(IdempotencyTTL()).SetFailIfExists(true))
+	if err == nil {
+		return record, true, nil
+	}
+	if !errors.Is(err, ErrDuplicateKey) {
+		return record, false, err
+	}
+
+	var existing model.IdempotencyRecord
+	if err = table.FindByID(id, &existing); err != nil {
+		return record, false, err
+	}
+	if existing.Done || existing.Created > now.Add(-IdempotencyLease).Unix() {
+		return existing, false, nil
+	}
+
+	// Only one of the retries racing for the stale claim wins it
+	claimed, err = table.UpdateByIDIf(id, bson.M{"done": false, "created": existing.Created}, bson.M{"$set": bson.M{
+		"request_hash": requestHash,
+		"created":      record.Created,
+	}})
+	if err != nil || claimed {
+		return record, claimed, err
+	}
+	if err = table.FindByID(id, &existing); err != nil {
+		return record, false, err
+	}
+	return existing, false, nil
+}
+
+// ReleaseIdempotencyKey Forget a claim whose request failed before its response was stored, so retries run again
+func ReleaseIdempotencyKey(id string) error {
+	table := SetModel(setting.Cfg.DB.Database, "idempotency_keys")
+	_, err := table.DeleteMany(bson.M{"_id": id, "done": false}, Find().SetKey(id))
+	return err
+}
+
+// CompleteIdempotencyKey Store the response replayed to retries of the request
+func CompleteIdempotencyKey(id string, response string) error {
+	table := SetModel(setting.Cfg.DB.Database, "idempotency_keys")
+	return table.UpdateByID(id, bson.M{"$set": bson.M{"done": true, "response": response}})
+}
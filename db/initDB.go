@@ -1,6 +1,7 @@
 package db
 
 import (
+	"errors"
 	"linkshortener/log"
 	"linkshortener/setting"
 	"strings"
@@ -9,16 +10,23 @@ import (
 var MongoDB *LlsMongoDB
 var BadgerDB *LlsBadgerDB
 
+// ErrDuplicateKey A document with the same _id already exists, see InsertOptions.FailIfExists
+var ErrDuplicateKey = errors.New("duplicate key")
+
 type Tabler interface {
 	SetDB(db interface{})
-	InsertOne(document interface{}, autoKey bool) (interface{}, error)
+	InsertOne(document interface{}, autoKey bool, opts ...*InsertOptions) (interface{}, error)
 	UpdateOne(filter interface{}, result interface{}) error //todo: TEST
 	UpdateByID(id string, update interface{}) error
+	UpsertByID(id string, update interface{}) error
+	UpdateByIDIf(id string, filter interface{}, update interface{}) (bool, error)
 	FindByID(id interface{}, result interface{}) error
 	FindOne(filter interface{}, result interface{}) error //todo: TEST
 	Find(filter interface{}, result interface{}, opts *FindOptions) error
+	FindEach(filter interface{}, opts *FindOptions, fn func(decode func(result interface{}) error) error) error
 	CreateOneIndex(index interface{}, opts ...interface{}) error
 	CountDocuments(filter interface{}, opt *FindOptions) (int64, error)
+	DeleteMany(filter interface{}, opt *FindOptions) (int64, error)
 }
 
 func NewModel(dbName, tableName string) Tabler {
@@ -36,6 +36,40 @@ func InitModel() {
 		if err != nil {
 			log.PanicPrint("Failed to initialize MongoDB")
 		}
+		if AccessLogTTL() > 0 {
+			ttlIndex := mongo.IndexModel{
+				Keys: bson.M{
+					"expire_at": 1,
+				},
+				Options: options.Index().SetName("expire_at_ttl").SetExpireAfterSeconds(0),
+			}
+			err = statsTable.CreateOneIndex(ttlIndex)
+			if err != nil {
+				log.PanicPrint("Failed to initialize MongoDB")
+			}
+		}
+		rollupTable := NewModel(setting.Cfg.DB.Database, "link_access_rollup")
+		rollupIndex := mongo.IndexModel{
+			Keys: bson.M{
+				"hash": 1,
+			},
+			Options: options.Index().SetName("hash_index"),
+		}
+		err = rollupTable.CreateOneIndex(rollupIndex)
+		if err != nil {
+			log.PanicPrint("Failed to initialize MongoDB")
+		}
+		idempotencyTable := NewModel(setting.Cfg.DB.Database, "idempotency_keys")
+		idempotencyIndex := mongo.IndexModel{
+			Keys: bson.M{
+				"expire_at": 1,
+			},
+			Options: options.Index().SetName("expire_at_ttl").SetExpireAfterSeconds(0),
+		}
+		err = idempotencyTable.CreateOneIndex(idempotencyIndex)
+		if err != nil {
+			log.PanicPrint("Failed to initialize MongoDB")
+		}
 	default:
 		return
 	}
@@ -0,0 +1,177 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"linkshortener/log"
+	"linkshortener/model"
+	"linkshortener/setting"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	defaultLockoutMaxAttempts = 5
+	defaultLockoutBase        = 30 * time.Second
+	defaultLockoutMax         = time.Hour
+	defaultLockoutResetAfter  = 24 * time.Hour
+)
+
+// LockoutDuration The lockout after the given number of consecutive failures.
+// The first MAX_ATTEMPTS failures are free, then the lockout doubles with every failure up to MAX_LOCKOUT.
+func LockoutDuration(failures int64) time.Duration {
+	maxAttempts := int64(setting.Cfg.Lockout.MaxAttempts)
+	if maxAttempts <= 0 {
+		maxAttempts = defaultLockoutMaxAttempts
+	}
+	if failures < maxAttempts {
+		return 0
+	}
+	base := durationOr(setting.Cfg.Lockout.BaseLockout, defaultLockoutBase)
+	maxLockout := durationOr(setting.Cfg.Lockout.MaxLockout, defaultLockoutMax)
+
+	duration := base
+	for i := maxAttempts; i < failures && duration < maxLockout; i++ {
+		duration *= 2
+	}
+	return min(duration, maxLockout)
+}
+
+// LockedUntil The end of the longest lockout among the subjects, 0 if none of them is locked
+func LockedUntil(now time.Time, subjects ...string) int64 {
+	if !setting.Cfg.Lockout.EnableLockout {
+		return 0
+	}
+	table := SetModel(setting.Cfg.DB.Database, "auth_attempts")
+	var lockedUntil int64
+	for _, subject := range subjects {
+		var attempt model.AuthAttempt
+		if err := table.FindByID(subject, &attempt); err != nil {
+			continue
+		}
+		if attempt.LockedUntil > now.Unix() && attempt.LockedUntil > lockedUntil {
+			lockedUntil = attempt.LockedUntil
+		}
+	}
+	return lockedUntil
+}
+
+// lockoutUpdateRetries The number of times a failure is recounted after a concurrent failure changed the record first
+const lockoutUpdateRetries = 64
+
+// RecordFailure Count a failed check against every subject and lock them once they exceed the free attempts.
+// It returns the end of the longest resulting lockout, 0 if none is locked.
+func RecordFailure(now time.Time, subjects ...string) int64 {
+	if !setting.Cfg.Lockout.EnableLockout {
+		return 0
+	}
+	table := SetModel(setting.Cfg.DB.Database, "auth_attempts")
+	var lockedUntil int64
+	for _, subject := range subjects {
+		attempt, err := recordFailure(table, subject, now)
+		if err != nil {
+			log.WarnPrint("Failed to record the failed attempt of %s: %s", subject, err)
+			continue
+		}
+		if attempt.LockedUntil > now.Unix() {
+			lockedUntil = max(lockedUntil, attempt.LockedUntil)
+		}
+	}
+	return lockedUntil
+}
+
+// recordFailure Increase the failures of the subject and lock it in one conditional write.
+// The write only applies if the record is unchanged since it was read, so parallel guesses
+// cannot share a count, the losers read the new record and count again.
+func recordFailure(table Tabler, subject string, now time.Time) (model.AuthAttempt, error) {
+	resetAfter := durationOr(setting.Cfg.Lockout.ResetAfter, defaultLockoutResetAfter)
+	for retry := 0; retry < lockoutUpdateRetries; retry++ {
+		var current model.AuthAttempt
+		exists := table.FindByID(subject, &current) == nil
+
+		next := model.AuthAttempt{ID: subject, Failures: current.Failures + 1, LastFailure: now.Unix(), LockedUntil: current.LockedUntil}
+		if current.LastFailure < now.Add(-resetAfter).Unix() {
+			// The previous failures are too old to count
+			next.Failures = 1
+		}
+		if duration := LockoutDuration(next.Failures); duration > 0 {
+			next.LockedUntil = max(next.LockedUntil, now.Add(duration).Unix())
+		}
+
+		if !exists {
+			// locked_until is always stored so the sweeper can match the record
+			_, err := table.InsertOne(next, false, Insert().SetFailIfExists(true))
+			if errors.Is(err, ErrDuplicateKey) {
+				continue
+			}
+			return next, err
+		}
+
+		updated, err := table.UpdateByIDIf(subject, bson.M{
+			"failures":     current.Failures,
+			"last_failure": current.LastFailure,
+		}, bson.M{"$set": bson.M{
+			"failures":     next.Failures,
+			"last_failure": next.LastFailure,
+			"locked_until": next.LockedUntil,
+		}})
+		if err != nil {
+			return next, err
+		}
+		if updated {
+			return next, nil
+		}
+	}
+	return model.AuthAttempt{}, fmt.Errorf("the record kept changing after %d attempts", lockoutUpdateRetries)
+}
+
+// ResetFailures Forget the failures of the subjects after a successful check
+func ResetFailures(subjects ...string) {
+	if !setting.Cfg.Lockout.EnableLockout {
+		return
+	}
+	table := SetModel(setting.Cfg.DB.Database, "auth_attempts")
+	for _, subject := range subjects {
+		var attempt model.AuthAttempt
+		if err := table.FindByID(subject, &attempt); err != nil || attempt.Failures == 0 {
+			continue
+		}
+		_ = table.UpsertByID(subject, bson.M{"$set": bson.M{"failures": 0, "locked_until": 0}})
+	}
+}
+
+// SweepLockouts Remove the attempt records that are neither locked nor recent enough to count
+func SweepLockouts(now time.Time) (int64, error) {
+	resetAfter := durationOr(setting.Cfg.Lockout.ResetAfter, defaultLockoutResetAfter)
+	table := SetModel(setting.Cfg.DB.Database, "auth_attempts")
+	return table.DeleteMany(bson.M{
+		"last_failure": bson.M{"$lt": now.Add(-resetAfter).Unix()},
+		"locked_until": bson.M{"$lt": now.Unix()},
+	}, Find())
+}
+
+// InitLockout Start the sweeper of the attempt records if the lockout is enabled
+func InitLockout() {
+	if !setting.Cfg.Lockout.EnableLockout {
+		return
+	}
+	go func() {
+		for {
+			count, err := SweepLockouts(time.Now())
+			if err != nil {
+				log.WarnPrint("Lockout sweep failed: %s", err)
+			} else if count > 0 {
+				log.DebugPrint("Lockout sweep removed %d records", count)
+			}
+			time.Sleep(time.Hour)
+		}
+	}()
+}
+
+func durationOr(seconds int, fallback time.Duration) time.Duration {
+	if seconds <= 0 {
+		return fallback
+	}
+	return time.Duration(seconds) * time.Second
+}
@@ -147,7 +147,8 @@ func (t *MongoDBTable) CountDocuments(filter interface{}, _ *FindOptions) (int64
 	return count, err
 }
 
-func (t *MongoDBTable) InsertOne(document interface{}, autoKey bool) (interface{}, error) {
+func (t *MongoDBTable) InsertOne(document interface{}, autoKey bool, opts ...*InsertOptions) (interface{}, error) {
+	opt := mergeInsertOptions(opts...)
 	db := t.getDB()
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(db.Config.ExecuteTimeout)*time.Second)
 	defer func() {
@@ -171,11 +172,20 @@ func (t *MongoDBTable) InsertOne(document interface{}, autoKey bool) (interface{
 		}
 	}
 
+	if opt.TTL > 0 {
+		doc["expire_at"] = time.Now().Add(opt.TTL)
+		document = doc
+	}
+
 	result, err := db.Database.Collection(t.tableName).InsertOne(ctx, document)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, fmt.Errorf("%w: %v", ErrDuplicateKey, err)
+	}
 	if err != nil {
 		log.ErrorPrint("mongo InsertOne error %v", err)
+		return nil, err
 	}
-	return result.InsertedID, err
+	return result.InsertedID, nil
 }
 
 func (t *MongoDBTable) UpdateOne(filter interface{}, update interface{}) error {
@@ -204,6 +214,49 @@ func (t *MongoDBTable) UpdateByID(id string, update interface{}) error {
 	return err
 }
 
+func (t *MongoDBTable) UpsertByID(id string, update interface{}) error {
+	db := t.getDB()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(db.Config.ExecuteTimeout)*time.Second)
+	defer func() {
+		cancel()
+	}()
+	_, err := db.Database.Collection(t.tableName).UpdateByID(ctx, id, update, options.Update().SetUpsert(true))
+	if err != nil {
+		log.ErrorPrint("mongo UpsertByID error %v", err)
+	}
+	return err
+}
+
+// UpdateByIDIf applies the update only if the document also matches filter, the check and the update are atomic.
+// It reports whether the document was updated.
+func (t *MongoDBTable) UpdateByIDIf(id string, filter interface{}, update interface{}) (bool, error) {
+	db := t.getDB()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(db.Config.ExecuteTimeout)*time.Second)
+	defer func() {
+		cancel()
+	}()
+	result, err := db.Database.Collection(t.tableName).UpdateOne(ctx, bson.D{{Key: "_id", Value: id}, {Key: "$and", Value: bson.A{filter}}}, update)
+	if err != nil {
+		log.ErrorPrint("mongo UpdateByIDIf error %v", err)
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+func (t *MongoDBTable) DeleteMany(filter interface{}, _ *FindOptions) (int64, error) {
+	db := t.getDB()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(db.Config.ExecuteTimeout)*time.Second)
+	defer func() {
+		cancel()
+	}()
+	result, err := db.Database.Collection(t.tableName).DeleteMany(ctx, filter)
+	if err != nil {
+		log.ErrorPrint("mongo DeleteMany error %v", err)
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
 func (t *MongoDBTable) FindOne(filter interface{}, result interface{}) error {
 	db := t.getDB()
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(db.Config.ExecuteTimeout)*time.Second)
@@ -242,7 +295,11 @@ func (t *MongoDBTable) Find(filter interface{}, result interface{}, opt *FindOpt
 	defer func() {
 		cancel()
 	}()
-	cur, err := db.Database.Collection(t.tableName).Find(ctx, filter, options.Find().SetSkip(opt.Skip).SetLimit(opt.Limit).SetMin(opt.Min).SetMax(opt.Max))
+	findOptions := options.Find().SetSkip(opt.Skip).SetLimit(opt.Limit).SetMin(opt.Min).SetMax(opt.Max)
+	if opt.Sort != nil {
+		findOptions.SetSort(opt.Sort)
+	}
+	cur, err := db.Database.Collection(t.tableName).Find(ctx, filter, findOptions)
 	if err != nil {
 		log.ErrorPrint("mongo Find error %v", err)
 		return err
@@ -258,6 +315,40 @@ func (t *MongoDBTable) Find(filter interface{}, result interface{}, opt *FindOpt
 	return err
 }
 
+// FindEach streams the matching documents to fn one at a time instead of loading them all into memory.
+// decode unmarshals the current document into result, iteration stops at the first error returned by fn.
+func (t *MongoDBTable) FindEach(filter interface{}, opt *FindOptions, fn func(decode func(result interface{}) error) error) error {
+	db := t.getDB()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(db.Config.ExecuteTimeout)*time.Second)
+	defer func() {
+		cancel()
+	}()
+	findOptions := options.Find().SetSkip(opt.Skip).SetLimit(opt.Limit).SetMin(opt.Min).SetMax(opt.Max)
+	if opt.Sort != nil {
+		findOptions.SetSort(opt.Sort)
+	}
+	cur, err := db.Database.Collection(t.tableName).Find(ctx, filter, findOptions)
+	if err != nil {
+		log.ErrorPrint("mongo FindEach error %v", err)
+		return err
+	}
+	defer func(cur *mongo.Cursor, ctx context.Context) {
+		_ = cur.Close(ctx)
+	}(cur, context.Background())
+
+	// The cursor may outlive the execute timeout while the caller is streaming
+	for cur.Next(context.Background()) {
+		if err = fn(cur.Decode); err != nil {
+			return err
+		}
+	}
+	err = cur.Err()
+	if err != nil {
+		log.ErrorPrint("mongo FindEach cur error %v", err)
+	}
+	return err
+}
+
 func (t *MongoDBTable) CreateOneIndex(indexInterface interface{}, opts ...interface{}) error {
 	var createIndexesOptionsSlice []*options.CreateIndexesOptions
 
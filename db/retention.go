@@ -0,0 +1,61 @@
+package db
+
+import (
+	"linkshortener/log"
+	"linkshortener/setting"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// AccessLogTTL The retention period of raw access log rows, 0 means they are kept forever
+func AccessLogTTL() time.Duration {
+	if setting.Cfg.Retention.AccessLogDays <= 0 {
+		return 0
+	}
+	return time.Duration(setting.Cfg.Retention.AccessLogDays) * 24 * time.Hour
+}
+
+// AccessLogRetainedSince The oldest access time still guaranteed to be present, 0 if nothing is removed
+func AccessLogRetainedSince(now time.Time) int64 {
+	ttl := AccessLogTTL()
+	if ttl == 0 {
+		return 0
+	}
+	return now.Add(-ttl).Unix()
+}
+
+// SweepAccessLog Remove the access log rows older than the retention period.
+// New rows expire by themselves (Badger TTL / MongoDB TTL index), this catches rows written before retention was enabled.
+func SweepAccessLog() (int64, error) {
+	retainedSince := AccessLogRetainedSince(time.Now())
+	if retainedSince == 0 {
+		return 0, nil
+	}
+	table := SetModel(setting.Cfg.DB.Database, "link_access")
+	return table.DeleteMany(bson.M{"created": bson.M{"$lt": retainedSince}}, Find())
+}
+
+// InitRetention Start the access log sweeper if a retention period is configured
+func InitRetention() {
+	if AccessLogTTL() == 0 {
+		return
+	}
+	interval := time.Duration(setting.Cfg.Retention.SweepInterval) * time.Minute
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	log.InfoPrint("Access log retention: %d days, sweeping every %v", setting.Cfg.Retention.AccessLogDays, interval)
+
+	go func() {
+		for {
+			count, err := SweepAccessLog()
+			if err != nil {
+				log.WarnPrint("Access log sweep failed: %s", err)
+			} else if count > 0 {
+				log.DebugPrint("Access log sweep removed %d rows", count)
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
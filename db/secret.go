@@ -0,0 +1,54 @@
+package db
+
+import (
+	"errors"
+	"linkshortener/lib/tool"
+	"linkshortener/log"
+	"linkshortener/model"
+	"linkshortener/setting"
+	"time"
+)
+
+// tokenHashKeyLength The length of the generated manage token hash key
+const tokenHashKeyLength = 64
+
+// LoadSecret The secret stored under the name, it is generated and stored on the first call.
+// Concurrent first calls of several instances agree on the secret that was stored first.
+func LoadSecret(name string, length int) (string, error) {
+	table := SetModel(setting.Cfg.DB.Database, "secrets")
+	var secret model.ServerSecret
+	if err := table.FindByID(name, &secret); err == nil && secret.Value != "" {
+		return secret.Value, nil
+	}
+
+	value, err := tool.GetToken(length)
+	if err != nil {
+		return "", err
+	}
+	secret = model.ServerSecret{ID: name, Value: value, Created: time.Now().Unix()}
+	_, err = table.InsertOne(secret, false, Insert().SetFailIfExists(true))
+	if errors.Is(err, ErrDuplicateKey) {
+		if err = table.FindByID(name, &secret); err != nil {
+			return "", err
+		}
+		return secret.Value, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	log.InfoPrint("Generated the secret %s and stored it in the database", name)
+	return value, nil
+}
+
+// TokenHashKey The key of the manage token hashes, TOKEN_HASH_KEY or else a random key kept in the database.
+// The server does not start without one, a key anyone can derive would make the hashes reproducible.
+func TokenHashKey() string {
+	if setting.Cfg.TokenHashKey != "" {
+		return setting.Cfg.TokenHashKey
+	}
+	key, err := LoadSecret("token_hash_key", tokenHashKeyLength)
+	if err != nil || key == "" {
+		log.PanicPrint("TOKEN_HASH_KEY is empty and no key could be stored in the database: %v", err)
+	}
+	return key
+}
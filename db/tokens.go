@@ -0,0 +1,50 @@
+package db
+
+import (
+	"linkshortener/lib/passhash"
+	"linkshortener/log"
+	"linkshortener/model"
+	"linkshortener/setting"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// MigrateTokens Replace the plaintext manage tokens stored by older versions with their keyed hash.
+// Each link is only updated if its token is still the plaintext one, so running it twice is harmless.
+func MigrateTokens() {
+	table := SetModel(setting.Cfg.DB.Database, "links")
+	var plaintext []model.Link
+	err := table.FindEach(bson.M{}, Find(), func(decode func(result interface{}) error) error {
+		var link model.Link
+		if err := decode(&link); err != nil {
+			return err
+		}
+		if link.Token != "" && !passhash.IsHashedToken(link.Token) {
+			plaintext = append(plaintext, link)
+		}
+		return nil
+	})
+	if err != nil {
+		log.ErrorPrint("Manage token migration scan failed: %s", err)
+		return
+	}
+	if len(plaintext) == 0 {
+		return
+	}
+
+	migrated := 0
+	for _, link := range plaintext {
+		ok, err := table.UpdateByIDIf(link.ShortHash,
+			bson.D{{Key: "token", Value: link.Token}},
+			bson.M{"$set": bson.M{"token": passhash.HashToken(link.Token)}},
+		)
+		if err != nil {
+			log.WarnPrint("Manage token migration of %s failed: %s", link.ShortHash, err)
+			continue
+		}
+		if ok {
+			migrated++
+		}
+	}
+	log.InfoPrint("Manage token migration hashed %d of %d plaintext tokens", migrated, len(plaintext))
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"linkshortener/lib/domainlist"
+	"linkshortener/model"
+	"linkshortener/setting"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+)
+
+func TestDomainList(t *testing.T) {
+	previous := setting.Cfg.DomainList
+	defer func() {
+		setting.Cfg.DomainList = previous
+		_ = domainlist.Reload()
+	}()
+
+	dir := t.TempDir()
+	denyFile := filepath.Join(dir, "deny.txt")
+	allowFile := filepath.Join(dir, "allow.txt")
+	writeList := func(path string, content string) {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeList(denyFile, "# phishing\nEvil.example\n*.bad.example\n203.0.113.0/24\n2001:db8::1\nbücher.example\n")
+	setting.Cfg.DomainList = model.DomainListConfig{AllowListFile: allowFile, DenyListFile: denyFile}
+
+	t.Run("Deny List", func(t *testing.T) {
+		writeList(allowFile, "")
+		assert.Equal(t, domainlist.Reload(), nil)
+
+		assert.Equal(t, domainlist.Check("example.com"), nil)
+		assert.Equal(t, errors.Is(domainlist.Check("evil.example"), domainlist.ErrDenied), true)
+		assert.Equal(t, errors.Is(domainlist.Check("EVIL.example."), domainlist.ErrDenied), true)
+		assert.Equal(t, errors.Is(domainlist.Check("login.bad.example"), domainlist.ErrDenied), true)
+		assert.Equal(t, domainlist.Check("bad.example"), nil)
+		assert.Equal(t, domainlist.Check("notbad.example"), nil)
+		assert.Equal(t, errors.Is(domainlist.Check("203.0.113.9"), domainlist.ErrDenied), true)
+		assert.Equal(t, domainlist.Check("203.0.114.9"), nil)
+		assert.Equal(t, errors.Is(domainlist.Check("[2001:db8::1]"), domainlist.ErrDenied), true)
+		// The IDN is matched in its punycode form
+		assert.Equal(t, errors.Is(domainlist.Check("xn--bcher-kva.example"), domainlist.ErrDenied), true)
+	})
+
+	t.Run("Allow List", func(t *testing.T) {
+		writeList(allowFile, "example.com\n*.example.org\n")
+		assert.Equal(t, domainlist.Reload(), nil)
+
+		assert.Equal(t, domainlist.Check("example.com"), nil)
+		assert.Equal(t, domainlist.Check("docs.example.org"), nil)
+		assert.Equal(t, errors.Is(domainlist.Check("example.net"), domainlist.ErrNotAllowed), true)
+		assert.Equal(t, errors.Is(domainlist.Check("evil.example"), domainlist.ErrDenied), true)
+	})
+
+	t.Run("Invalid File Keeps Lists", func(t *testing.T) {
+		writeList(denyFile, "not-a-cidr/99\n")
+		assert.NotEqual(t, domainlist.Reload(), nil)
+		assert.Equal(t, errors.Is(domainlist.Check("evil.example"), domainlist.ErrDenied), true)
+	})
+}
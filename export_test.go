@@ -0,0 +1,85 @@
+package main
+
+import (
+	"io"
+	"linkshortener/controller"
+	"linkshortener/db"
+	"linkshortener/lib/export"
+	"linkshortener/lib/passhash"
+	"linkshortener/model"
+	"linkshortener/setting"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+)
+
+func TestExportCSVHeaders(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	contentType, err := export.ContentType(export.FormatCSV)
+	assert.Equal(t, err, nil)
+
+	writer, err := export.NewRowWriter(export.FormatCSV, recorder)
+	assert.Equal(t, err, nil)
+	// Creating the writer must not commit the response, the headers are still set afterwards
+	assert.Equal(t, recorder.Body.Len(), 0)
+	recorder.Header().Set("Content-Type", contentType)
+	recorder.Header().Set("Content-Disposition", `attachment; filename="lls-stats-abc.csv"`)
+	recorder.Header().Set("Cache-Control", "no-store")
+
+	assert.Equal(t, writer.WriteRow([]string{"hash", "country"}), nil)
+	assert.Equal(t, writer.WriteRow([]string{"abc", "中国"}), nil)
+	assert.Equal(t, writer.Close(), nil)
+
+	response := recorder.Result()
+	assert.Equal(t, response.Header.Get("Content-Type"), "text/csv; charset=utf-8")
+	assert.Equal(t, response.Header.Get("Content-Disposition"), `attachment; filename="lls-stats-abc.csv"`)
+	assert.Equal(t, response.Header.Get("Cache-Control"), "no-store")
+	assert.Equal(t, recorder.Body.String(), "\xEF\xBB\xBFhash,country\nabc,中国\n")
+
+	contentType, err = export.ContentType(export.FormatXLSX)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, contentType, "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	_, err = export.ContentType("pdf")
+	assert.NotEqual(t, err, nil)
+}
+
+func TestExportStatsAborted(t *testing.T) {
+	router := captchaRouter(t)
+	passhash.InitPasshash("test-key")
+	insertLink(t, model.Link{ShortHash: "broken", URL: "https://example.com/", Token: passhash.HashToken("token")})
+
+	// The second record cannot be decoded, so reading fails after the first row was written
+	type accessRecord struct {
+		ID      string      `bson:"_id"`
+		Hash    string      `bson:"hash"`
+		Created interface{} `bson:"created"`
+	}
+	stats := db.SetModel(setting.Cfg.DB.Database, "link_access")
+	for _, record := range []accessRecord{{"broken:1", "broken", int64(1700000000)}, {"broken:2", "broken", "yesterday"}} {
+		_, err := stats.InsertOne(record, false)
+		assert.Equal(t, err, nil)
+	}
+
+	router.POST("/api/export_stats_link", controller.ExportStatsLink)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	jar, _ := cookiejar.New(nil)
+	client := &http.Client{Jar: jar}
+	captcha, err := client.Get(server.URL + "/captcha")
+	assert.Equal(t, err, nil)
+	_ = captcha.Body.Close()
+
+	// The download must fail instead of ending like a complete file
+	response, err := client.Post(server.URL+"/api/export_stats_link", "application/json",
+		strings.NewReader(`{"hash":"broken","captcha":"1234","token":"token"}`))
+	if err == nil {
+		_, err = io.ReadAll(response.Body)
+		_ = response.Body.Close()
+	}
+	assert.NotEqual(t, err, nil)
+}
@@ -3,6 +3,8 @@ package fs
 import (
 	"linkshortener/i18n"
 	"linkshortener/lib/ip2location"
+	"linkshortener/lib/page"
+	"linkshortener/lib/tool"
 	"linkshortener/lib/uap"
 	"linkshortener/log"
 	"net/http"
@@ -74,3 +76,13 @@ func InitI18n() {
 	}
 	i18n.InitI18n(jpBytes, cnBytes, usBytes)
 }
+
+func InitTemplates() {
+	for _, name := range []string{"interstitial", "password", "preview"} {
+		templateBytes, err := fs.ReadFile(StatikFS, tool.ConcatStrings("/resources/templates/", name, ".html"))
+		if err != nil {
+			log.PanicPrint("Loading embedded template(%s) exception: %s", name, err)
+		}
+		page.InitTemplate(name, templateBytes)
+	}
+}
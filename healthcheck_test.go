@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"linkshortener/db"
+	"linkshortener/lib/healthcheck"
+	"linkshortener/lib/urlpolicy"
+	"linkshortener/model"
+	"linkshortener/setting"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+)
+
+func TestHealthCheck(t *testing.T) {
+	var inFlight, maxInFlight atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			seen := maxInFlight.Load()
+			if current <= seen || maxInFlight.CompareAndSwap(seen, current) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+
+		switch r.URL.Path {
+		case "/ok":
+			w.WriteHeader(http.StatusOK)
+		case "/moved":
+			http.Redirect(w, r, "/ok", http.StatusFound)
+		case "/get-only":
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		case "/broken":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	previous := setting.Cfg.Destination
+	defer func() {
+		setting.Cfg.Destination = previous
+		urlpolicy.InitPolicy()
+	}()
+
+	t.Run("Restricted Address", func(t *testing.T) {
+		setting.Cfg.Destination = model.DestinationConfig{}
+		urlpolicy.InitPolicy()
+		checker := healthcheck.NewChecker(1, 0, time.Second)
+
+		health := checker.Check(context.Background(), server.URL+"/ok")
+		assert.Equal(t, health.Healthy, false)
+		assert.Equal(t, health.Error, urlpolicy.ErrAddress.Error())
+	})
+
+	// The test server listens on the loopback interface
+	setting.Cfg.Destination = model.DestinationConfig{AllowPrivateAddresses: true}
+	urlpolicy.InitPolicy()
+
+	t.Run("Status", func(t *testing.T) {
+		checker := healthcheck.NewChecker(1, 0, time.Second)
+		cases := map[string]int{"/ok": 200, "/moved": 200, "/get-only": 200, "/missing": 404, "/broken": 500}
+		for path, status := range cases {
+			health := checker.Check(context.Background(), server.URL+path)
+			assert.Equal(t, health.Status, status)
+			assert.Equal(t, health.Healthy, status < 400)
+			assert.NotEqual(t, health.CheckedAt, int64(0))
+		}
+
+		health := checker.Check(context.Background(), "http://127.0.0.1:1/")
+		assert.Equal(t, health.Healthy, false)
+		assert.NotEqual(t, health.Error, "")
+	})
+
+	t.Run("Concurrency And Host Rate", func(t *testing.T) {
+		maxInFlight.Store(0)
+		targets := make([]healthcheck.Target, 0, 12)
+		for i := 0; i < 12; i++ {
+			targets = append(targets, healthcheck.Target{Hash: string(rune('a' + i)), URL: server.URL + "/ok"})
+		}
+
+		// Every request goes to the same host, 20 requests per second spread 12 of them over about half a second
+		checker := healthcheck.NewChecker(4, 20, time.Second)
+		var mu sync.Mutex
+		results := make(map[string]model.LinkHealth)
+		start := time.Now()
+		checker.Run(context.Background(), targets, func(target healthcheck.Target, health model.LinkHealth) {
+			mu.Lock()
+			defer mu.Unlock()
+			results[target.Hash] = health
+		})
+
+		assert.Equal(t, len(results), 12)
+		for _, health := range results {
+			assert.Equal(t, health.Status, 200)
+		}
+		assert.Equal(t, time.Since(start) >= 500*time.Millisecond, true)
+		assert.Equal(t, maxInFlight.Load() <= 4, true)
+	})
+}
+
+func TestLinkHealthBadger(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	useBadgerDB(t)
+	previous := setting.Cfg.Destination
+	defer func() {
+		setting.Cfg.Destination = previous
+		urlpolicy.InitPolicy()
+	}()
+	setting.Cfg.Destination = model.DestinationConfig{AllowPrivateAddresses: true}
+	urlpolicy.InitPolicy()
+
+	table := db.SetModel(setting.Cfg.DB.Database, "links")
+	_, err := table.InsertOne(model.Link{ShortHash: "health", URL: server.URL}, false)
+	assert.Equal(t, err, nil)
+
+	healthy, err := db.CheckLinkHealth(context.Background(), healthcheck.NewChecker(1, 0, time.Second))
+	assert.Equal(t, err, nil)
+	assert.Equal(t, healthy, 0)
+
+	// The result is a sub-document of the link and must survive the read
+	var link model.Link
+	assert.Equal(t, table.FindByID("health", &link), nil)
+	assert.Equal(t, link.URL, server.URL)
+	assert.Equal(t, link.Health.Healthy, false)
+	assert.Equal(t, link.Health.Status, http.StatusServiceUnavailable)
+	assert.NotEqual(t, link.Health.CheckedAt, int64(0))
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"linkshortener/controller"
+	"linkshortener/db"
+	"linkshortener/i18n"
+	"linkshortener/lib/page"
+	"linkshortener/lib/uap"
+	"linkshortener/log"
+	"linkshortener/model"
+	"linkshortener/setting"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-contrib/sessions/memstore"
+	"github.com/gin-gonic/gin"
+)
+
+// testSessionSecret Signs the unlock cookies of the test router
+const testSessionSecret = "test-session-secret"
+
+// testCaptcha The captcha answer captchaRouter keeps in the session
+const testCaptcha = "1234"
+
+var routerOnce sync.Once
+
+// useBadgerDB Point the db package at an in-memory BadgerDB until the test ends
+func useBadgerDB(t *testing.T) *badger.DB {
+	t.Helper()
+	badgerDB, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	previousDB, previousBadgerDB := setting.Cfg.DB, db.BadgerDB
+	setting.Cfg.DB.Type = "BADGERDB"
+	db.BadgerDB = (&db.LlsBadgerDB{}).SetBadgerDB(badgerDB)
+	t.Cleanup(func() {
+		setting.Cfg.DB, db.BadgerDB = previousDB, previousBadgerDB
+		_ = badgerDB.Close()
+	})
+	return badgerDB
+}
+
+// useRouter The router of the server backed by an in-memory BadgerDB, built once from the embedded resources on disk.
+// The settings are restored when the test ends, so tests may change them freely.
+func useRouter(t *testing.T) http.Handler {
+	t.Helper()
+	routerOnce.Do(func() {
+		// The log stays on stdout outside the dev mode only if it is opened in the dev mode
+		setting.Cfg.RunMode = "dev"
+		log.InitLog()
+		setting.Cfg.RunMode = "test"
+
+		i18n.InitI18n(readResource(t, "lang/ja-JP.json"), readResource(t, "lang/zh-CN.json"), readResource(t, "lang/en-US.json"))
+		for _, name := range []string{"interstitial", "password", "preview"} {
+			page.InitTemplate(name, readResource(t, "templates/"+name+".html"))
+		}
+		uap.InitUap(readResource(t, "uaparser.yaml"))
+
+		setting.Cfg.HTTP = model.HTTPConfig{BasePath: "/", SoftRedirectBasePath: "/", SessionSecret: testSessionSecret}
+		setting.Cfg.HTTPLimiter = model.HTTPLimiterConfig{}
+		setting.Cfg.Stream.EnableStream = true
+		setting.Cfg.Bulk.EnableBulk = true
+		controller.InitController()
+		controller.InitRouter()
+	})
+
+	previous := setting.Cfg
+	t.Cleanup(func() { setting.Cfg = previous })
+	// Visits sending DNT are not logged, see visit
+	setting.Cfg.Privacy.HonorDNT = true
+	useBadgerDB(t)
+	return controller.Router()
+}
+
+// captchaRouter A router with the session store of the server whose /captcha stores testCaptcha instead of drawing one.
+// The tests mount the handlers that check the captcha on it.
+func captchaRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	useRouter(t)
+	router := gin.New()
+	router.Use(sessions.Sessions("session", memstore.NewStore([]byte(testSessionSecret))))
+	router.GET("/captcha", func(c *gin.Context) {
+		session := sessions.Default(c)
+		session.Set("captcha", testCaptcha)
+		_ = session.Save()
+	})
+	return router
+}
+
+// postWithCaptcha POST the JSON body in a session of captchaRouter
+func postWithCaptcha(router http.Handler, path string, body string) *httptest.ResponseRecorder {
+	captcha := serve(router, httptest.NewRequest(http.MethodGet, "/captcha", nil))
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	for _, cookie := range captcha.Result().Cookies() {
+		req.AddCookie(cookie)
+	}
+	return serve(router, req)
+}
+
+// serve Send the request to the handler and record the response
+func serve(handler http.Handler, req *http.Request) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	return recorder
+}
+
+// visit Open the short link as a browser that opted out of tracking,
+// so no access log is written in the background after the test ends
+func visit(handler http.Handler, req *http.Request) *httptest.ResponseRecorder {
+	req.Header.Set("DNT", "1")
+	return serve(handler, req)
+}
+
+// insertLink Store the link as GenerateLink would
+func insertLink(t *testing.T, link model.Link) {
+	t.Helper()
+	if _, err := db.SetModel(setting.Cfg.DB.Database, "links").InsertOne(link, false); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// insertAccess Store the access record as accessLogWorker would
+func insertAccess(t *testing.T, linkInfo model.LinkInfo) {
+	t.Helper()
+	table := db.SetModel(setting.Cfg.DB.Database, "link_access")
+	if _, err := table.InsertOne(linkInfo, true, db.Insert().SetKeyPrefix(linkInfo.Hash)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readResource(t *testing.T, name string) []byte {
+	data, err := os.ReadFile("static/resources/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
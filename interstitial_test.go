@@ -0,0 +1,44 @@
+package main
+
+import (
+	"linkshortener/model"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+)
+
+func TestInterstitialPage(t *testing.T) {
+	router := useRouter(t)
+	insertLink(t, model.Link{ShortHash: "notice", URL: "https://example.com/sale?a=1&b=2", Interstitial: true,
+		Memo: url.QueryEscape("<script>alert(1)</script> Spring sale")})
+
+	// The visitor sees where the link goes before following it
+	response := visit(router, httptest.NewRequest(http.MethodGet, "/s/notice", nil))
+	assert.Equal(t, response.Code, http.StatusOK)
+	assert.Equal(t, response.Header().Get("Location"), "")
+	assert.Equal(t, response.Header().Get("Content-Type"), "text/html; charset=utf-8")
+	assert.Equal(t, response.Header().Get("Cache-Control"), "no-store")
+	assert.Equal(t, strings.HasPrefix(response.Header().Get("Content-Security-Policy"), "default-src 'none';"), true)
+
+	body := response.Body.String()
+	assert.Equal(t, strings.Contains(body, `<p class="domain">example.com</p>`), true)
+	assert.Equal(t, strings.Contains(body, `href="https://example.com/sale?a=1&amp;b=2"`), true)
+	assert.Equal(t, strings.Contains(body, "&lt;script&gt;alert(1)&lt;/script&gt; Spring sale"), true)
+	assert.Equal(t, strings.Contains(body, "<script>"), false)
+}
+
+func TestInterstitialUnsafeScheme(t *testing.T) {
+	router := useRouter(t)
+	insertLink(t, model.Link{ShortHash: "unsafe", URL: "javascript:alert(1)", Interstitial: true})
+
+	// A destination that could run script is shown without a link to follow
+	response := visit(router, httptest.NewRequest(http.MethodGet, "/s/unsafe", nil))
+	assert.Equal(t, response.Code, http.StatusOK)
+	body := response.Body.String()
+	assert.Equal(t, strings.Contains(body, `class="continue"`), false)
+	assert.Equal(t, strings.Contains(body, `<p class="domain">javascript:</p>`), true)
+}
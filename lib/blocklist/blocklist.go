@@ -0,0 +1,247 @@
+package blocklist
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"linkshortener/lib/domainlist"
+	"linkshortener/lib/tool"
+	"linkshortener/log"
+	"linkshortener/model"
+	"linkshortener/setting"
+	"net/netip"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// urlEntry A listed URL, prefix entries were written with a trailing * in the feed
+type urlEntry struct {
+	path   string
+	prefix bool
+	feed   string
+}
+
+// index The entries of every feed, URLs are grouped by host so a check only compares the URLs of one host
+type index struct {
+	hosts map[string]string
+	urls  map[string][]urlEntry
+	size  int
+}
+
+var current atomic.Pointer[index]
+
+// InitBlocklist Load the feeds and reload them on the interval, onReload runs after every successful load
+func InitBlocklist(onReload func()) {
+	if !setting.Cfg.Blocklist.EnableBlocklist {
+		return
+	}
+	interval := time.Duration(setting.Cfg.Blocklist.ReloadInterval) * time.Minute
+	if interval <= 0 {
+		interval = time.Hour
+	}
+
+	go func() {
+		for {
+			size, err := Reload()
+			if err != nil {
+				log.WarnPrint("Load threat feeds failed, the previous feeds stay in use: %s", err)
+			} else {
+				log.InfoPrint("Threat feeds loaded: %d entries", size)
+				if onReload != nil {
+					onReload()
+				}
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
+
+// Reload Read every feed file in FEED_DIR, the feeds in use are only replaced if all of them load.
+// Files ending with .csv are read as CSV (URLhaus, PhishTank), the first field holding a URL of each row is listed.
+// Other files list one URL, host or hosts file entry per line.
+func Reload() (int, error) {
+	dir := setting.Cfg.Blocklist.FeedDir
+	if dir == "" {
+		return 0, errors.New("FEED_DIR is empty")
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, err
+	}
+
+	idx := &index{hosts: make(map[string]string), urls: make(map[string][]urlEntry)}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		if err = idx.loadFeed(filepath.Join(dir, entry.Name())); err != nil {
+			return 0, err
+		}
+	}
+	current.Store(idx)
+	return idx.size, nil
+}
+
+// CheckURL Reports why the URL is listed by a feed, ok is false when it is not listed
+func CheckURL(rawURL string) (reason string, ok bool) {
+	idx := current.Load()
+	if idx == nil {
+		return "", false
+	}
+	host, path, valid := normalizeURL(rawURL)
+	if !valid {
+		return "", false
+	}
+
+	for h := host; h != ""; h = parentDomain(h) {
+		if feed, listed := idx.hosts[h]; listed {
+			return tool.ConcatStrings("host ", h, " is listed by ", feed), true
+		}
+	}
+	for _, entry := range idx.urls[host] {
+		if path == entry.path || (entry.prefix && strings.HasPrefix(path, entry.path)) {
+			return tool.ConcatStrings("URL is listed by ", entry.feed), true
+		}
+	}
+	return "", false
+}
+
+// CheckLink Reports why one of the destinations of the link is listed by a feed
+func CheckLink(link model.Link) (reason string, ok bool) {
+	for _, destination := range Destinations(link) {
+		if reason, ok = CheckURL(destination); ok {
+			return reason, true
+		}
+	}
+	return "", false
+}
+
+// Destinations Every URL the link may redirect to
+func Destinations(link model.Link) []string {
+	destinations := []string{link.URL}
+	if link.GeoFallback != "" {
+		destinations = append(destinations, link.GeoFallback)
+	}
+	for _, rule := range link.GeoRules {
+		destinations = append(destinations, rule.URL)
+	}
+	for _, rule := range link.DeviceRules {
+		destinations = append(destinations, rule.URL)
+		if rule.Fallback != "" {
+			destinations = append(destinations, rule.Fallback)
+		}
+	}
+	for _, rule := range link.ScheduleRules {
+		destinations = append(destinations, rule.URL)
+	}
+	for _, variant := range link.Variants {
+		destinations = append(destinations, variant.URL)
+	}
+	return destinations
+}
+
+func (idx *index) loadFeed(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	feed := filepath.Base(path)
+
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		reader := csv.NewReader(file)
+		reader.Comment = '#'
+		reader.FieldsPerRecord = -1
+		reader.LazyQuotes = true
+		for {
+			record, err := reader.Read()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("%s: %w", feed, err)
+			}
+			for _, field := range record {
+				if strings.Contains(field, "://") {
+					idx.add(strings.TrimSpace(field), feed)
+					break
+				}
+			}
+		}
+	}
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		// hosts file entries, e.g. 0.0.0.0 phishing.example
+		if fields := strings.Fields(line); len(fields) >= 2 {
+			if _, err := netip.ParseAddr(fields[0]); err == nil {
+				line = fields[1]
+			} else {
+				line = fields[0]
+			}
+		}
+		idx.add(line, feed)
+	}
+	if err = scanner.Err(); err != nil {
+		return fmt.Errorf("%s: %w", feed, err)
+	}
+	return nil
+}
+
+func (idx *index) add(entry string, feed string) {
+	if !strings.Contains(entry, "://") {
+		host := domainlist.NormalizeHost(strings.TrimPrefix(entry, "*."))
+		if host != "" {
+			idx.hosts[host] = feed
+			idx.size++
+		}
+		return
+	}
+
+	prefix := strings.HasSuffix(entry, "*")
+	host, path, valid := normalizeURL(strings.TrimSuffix(entry, "*"))
+	if !valid {
+		return
+	}
+	idx.urls[host] = append(idx.urls[host], urlEntry{path: path, prefix: prefix, feed: feed})
+	idx.size++
+}
+
+// normalizeURL The normalized host and escaped path with query of a URL, the scheme, port and fragment are ignored
+func normalizeURL(rawURL string) (host string, path string, ok bool) {
+	parsedURL, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || parsedURL.Hostname() == "" {
+		return "", "", false
+	}
+	host = domainlist.NormalizeHost(parsedURL.Hostname())
+	path = parsedURL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if parsedURL.RawQuery != "" {
+		path = tool.ConcatStrings(path, "?", parsedURL.RawQuery)
+	}
+	return host, path, true
+}
+
+// parentDomain example.com for www.example.com, empty for a top level label or an IP
+func parentDomain(host string) string {
+	if _, err := netip.ParseAddr(host); err == nil {
+		return ""
+	}
+	_, parent, found := strings.Cut(host, ".")
+	if !found || !strings.Contains(parent, ".") {
+		return ""
+	}
+	return parent
+}
@@ -0,0 +1,166 @@
+package bulk
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"linkshortener/model"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrEmpty    = errors.New("the batch has no links")
+	ErrTooLarge = errors.New("the batch has too many links")
+)
+
+// columns The CSV columns, named like the JSON fields
+var columns = []string{"link", "pwd", "expire", "memo", "alias"}
+
+// ParseJSON Read a JSON array of links, ErrTooLarge is returned as soon as the array has more than maxItems
+func ParseJSON(r io.Reader, maxItems int) ([]model.BulkLinkItem, error) {
+	decoder := json.NewDecoder(r)
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		return nil, errors.New("the body must be a JSON array")
+	}
+
+	var items []model.BulkLinkItem
+	for decoder.More() {
+		if len(items) == maxItems {
+			return nil, ErrTooLarge
+		}
+		var item model.BulkLinkItem
+		if err = decoder.Decode(&item); err != nil {
+			return nil, fmt.Errorf("item %d: %w", len(items), err)
+		}
+		items = append(items, item)
+	}
+	if _, err = decoder.Token(); err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, ErrEmpty
+	}
+	return items, nil
+}
+
+// ParseCSV Read links from a CSV file whose first row names the columns, only the link column is required.
+// ErrTooLarge is returned as soon as the file has more than maxItems rows.
+func ParseCSV(r io.Reader, maxItems int) ([]model.BulkLinkItem, error) {
+	reader := bufio.NewReader(r)
+	// Spreadsheet applications prepend a UTF-8 BOM
+	if bom, err := reader.Peek(3); err == nil && bytes.Equal(bom, []byte("\xEF\xBB\xBF")) {
+		_, _ = reader.Discard(3)
+	}
+
+	csvReader := csv.NewReader(reader)
+	csvReader.TrimLeadingSpace = true
+	header, err := csvReader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, ErrEmpty
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !isColumn(name) {
+			return nil, fmt.Errorf("unknown column %q", name)
+		}
+		if _, exists := index[name]; exists {
+			return nil, fmt.Errorf("duplicate column %q", name)
+		}
+		index[name] = i
+	}
+	if _, exists := index["link"]; !exists {
+		return nil, errors.New("the link column is missing")
+	}
+
+	var items []model.BulkLinkItem
+	for {
+		record, err := csvReader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(items) == maxItems {
+			return nil, ErrTooLarge
+		}
+
+		row := len(items) + 2
+		field := func(name string) string {
+			if i, exists := index[name]; exists {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		item := model.BulkLinkItem{
+			URL:      field("link"),
+			PASSWORD: field("pwd"),
+			MEMO:     field("memo"),
+			Alias:    field("alias"),
+		}
+		if expire := field("expire"); expire != "" {
+			if item.EXPIRE, err = strconv.ParseInt(expire, 10, 64); err != nil {
+				return nil, fmt.Errorf("row %d: expire is not a number", row)
+			}
+		}
+		items = append(items, item)
+	}
+	if len(items) == 0 {
+		return nil, ErrEmpty
+	}
+	return items, nil
+}
+
+func isColumn(name string) bool {
+	for _, column := range columns {
+		if column == name {
+			return true
+		}
+	}
+	return false
+}
+
+// RequestHash Identifies the content of a batch, a retry with the same Idempotency-Key must send the same links
+func RequestHash(items []model.BulkLinkItem) string {
+	data, _ := json.Marshal(items)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Each Call fn for every index in [0, n) with at most concurrency calls running at the same time
+func Each(n int, concurrency int, fn func(i int)) {
+	concurrency = max(min(concurrency, n), 1)
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				fn(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+}
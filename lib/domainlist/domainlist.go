@@ -0,0 +1,201 @@
+package domainlist
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"linkshortener/lib/tool"
+	"linkshortener/log"
+	"linkshortener/setting"
+	"net/netip"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/idna"
+)
+
+var (
+	ErrDenied     = errors.New("host is on the deny list")
+	ErrNotAllowed = errors.New("host is not on the allow list")
+)
+
+// hostSet The entries of one list file
+type hostSet struct {
+	exact    map[string]struct{}
+	suffixes []string // ".example.com" for the entry *.example.com
+	prefixes []netip.Prefix
+	modTime  time.Time
+}
+
+type lists struct {
+	allow *hostSet
+	deny  *hostSet
+}
+
+var current atomic.Pointer[lists]
+
+// InitDomainList Load the allow and deny lists and watch the files for changes
+func InitDomainList() {
+	if err := Reload(); err != nil {
+		log.PanicPrint("Load domain lists failed: %s", err)
+	}
+	interval := time.Duration(setting.Cfg.DomainList.ReloadInterval) * time.Second
+	if interval <= 0 || (setting.Cfg.DomainList.AllowListFile == "" && setting.Cfg.DomainList.DenyListFile == "") {
+		return
+	}
+
+	go func() {
+		for {
+			time.Sleep(interval)
+			if !changed() {
+				continue
+			}
+			if err := Reload(); err != nil {
+				log.WarnPrint("Reload domain lists failed, the previous lists stay in use: %s", err)
+			} else {
+				log.InfoPrint("Domain lists reloaded")
+			}
+		}
+	}()
+}
+
+// Reload Read the list files configured in app.ini, the lists in use are only replaced if both files load
+func Reload() error {
+	allow, err := loadHostSet(setting.Cfg.DomainList.AllowListFile)
+	if err != nil {
+		return err
+	}
+	deny, err := loadHostSet(setting.Cfg.DomainList.DenyListFile)
+	if err != nil {
+		return err
+	}
+	current.Store(&lists{allow: allow, deny: deny})
+	return nil
+}
+
+// Check Reports why the host may not be shortened, nil if it may.
+// The deny list wins over the allow list, an empty or unset allow list allows every host.
+func Check(host string) error {
+	l := current.Load()
+	if l == nil || host == "" {
+		return nil
+	}
+	host = NormalizeHost(host)
+	if l.deny != nil && l.deny.match(host) {
+		return ErrDenied
+	}
+	if l.allow != nil && !l.allow.empty() && !l.allow.match(host) {
+		return ErrNotAllowed
+	}
+	return nil
+}
+
+// NormalizeHost Lower case IDNA (punycode) form of the host without the trailing dot, IP literals are left as they are
+func NormalizeHost(host string) string {
+	host = strings.TrimSuffix(strings.TrimSpace(host), ".")
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if _, err := netip.ParseAddr(host); err == nil {
+		return host
+	}
+	if ascii, err := idna.Lookup.ToASCII(host); err == nil {
+		return ascii
+	}
+	return strings.ToLower(host)
+}
+
+// changed Reports whether a list file was modified since it was loaded
+func changed() bool {
+	l := current.Load()
+	if l == nil {
+		return true
+	}
+	return fileChanged(setting.Cfg.DomainList.AllowListFile, l.allow) || fileChanged(setting.Cfg.DomainList.DenyListFile, l.deny)
+}
+
+func fileChanged(path string, set *hostSet) bool {
+	if path == "" {
+		return set != nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return set != nil
+	}
+	return set == nil || !info.ModTime().Equal(set.modTime)
+}
+
+// loadHostSet Parse a list file, one entry per line: example.com, *.example.com, 203.0.113.7 or 203.0.113.0/24.
+// Blank lines and lines starting with # are skipped. An unset path means no list.
+func loadHostSet(path string) (*hostSet, error) {
+	if path == "" {
+		return nil, nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	set := &hostSet{exact: make(map[string]struct{}), modTime: info.ModTime()}
+	scanner := bufio.NewScanner(file)
+	line := 0
+	for scanner.Scan() {
+		line++
+		entry := strings.TrimSpace(scanner.Text())
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+		switch {
+		case strings.Contains(entry, "/"):
+			prefix, err := netip.ParsePrefix(entry)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: invalid CIDR %s", path, line, entry)
+			}
+			set.prefixes = append(set.prefixes, prefix.Masked())
+		case strings.HasPrefix(entry, "*."):
+			set.suffixes = append(set.suffixes, tool.ConcatStrings(".", NormalizeHost(entry[2:])))
+		default:
+			host := NormalizeHost(entry)
+			if addr, err := netip.ParseAddr(host); err == nil {
+				set.prefixes = append(set.prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+				continue
+			}
+			set.exact[host] = struct{}{}
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+func (s *hostSet) empty() bool {
+	return len(s.exact) == 0 && len(s.suffixes) == 0 && len(s.prefixes) == 0
+}
+
+// match Reports whether the normalized host is listed, wildcards only cover subdomains and CIDRs only IP literals
+func (s *hostSet) match(host string) bool {
+	if _, ok := s.exact[host]; ok {
+		return true
+	}
+	if addr, err := netip.ParseAddr(host); err == nil {
+		addr = addr.Unmap()
+		for _, prefix := range s.prefixes {
+			if prefix.Contains(addr) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, suffix := range s.suffixes {
+		if strings.HasSuffix(host, suffix) {
+			return true
+		}
+	}
+	return false
+}
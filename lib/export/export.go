@@ -0,0 +1,187 @@
+package export
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+
+	// xlsxCellLimit The maximum number of characters a spreadsheet cell can hold
+	xlsxCellLimit = 32767
+)
+
+// RowWriter Writes tabular data row by row to the underlying writer without buffering the whole table
+type RowWriter interface {
+	WriteRow(row []string) error
+	Flush() error
+	Close() error
+	ContentType() string
+}
+
+// NewRowWriter Create a RowWriter of the given format, csv or xlsx
+func NewRowWriter(format string, w io.Writer) (RowWriter, error) {
+	switch strings.ToLower(format) {
+	case FormatCSV:
+		return newCSVWriter(w)
+	case FormatXLSX:
+		return newXLSXWriter(w)
+	default:
+		return nil, fmt.Errorf("unsupported export format: %s", format)
+	}
+}
+
+// ContentType The MIME type of the files of the given format, csv or xlsx
+func ContentType(format string) (string, error) {
+	switch strings.ToLower(format) {
+	case FormatCSV:
+		return "text/csv; charset=utf-8", nil
+	case FormatXLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", nil
+	default:
+		return "", fmt.Errorf("unsupported export format: %s", format)
+	}
+}
+
+type csvWriter struct {
+	w          io.Writer
+	writer     *csv.Writer
+	bomWritten bool
+}
+
+// newCSVWriter Nothing is written before the first row, so the response headers can still be set
+func newCSVWriter(w io.Writer) (*csvWriter, error) {
+	return &csvWriter{w: w, writer: csv.NewWriter(w)}, nil
+}
+
+func (c *csvWriter) WriteRow(row []string) error {
+	if !c.bomWritten {
+		// The UTF-8 BOM makes spreadsheet applications detect the encoding of non-ASCII values
+		if _, err := c.w.Write([]byte("\xEF\xBB\xBF")); err != nil {
+			return err
+		}
+		c.bomWritten = true
+	}
+	escaped := make([]string, len(row))
+	for i, value := range row {
+		escaped[i] = escapeFormula(value)
+	}
+	return c.writer.Write(escaped)
+}
+
+func (c *csvWriter) Flush() error {
+	c.writer.Flush()
+	return c.writer.Error()
+}
+
+func (c *csvWriter) Close() error {
+	return c.Flush()
+}
+
+func (c *csvWriter) ContentType() string {
+	contentType, _ := ContentType(FormatCSV)
+	return contentType
+}
+
+// escapeFormula Prevent spreadsheet applications from evaluating visitor supplied values as formulas
+func escapeFormula(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// xlsxWriter Streams a single sheet workbook, the zip entries are written sequentially so nothing is kept in memory
+type xlsxWriter struct {
+	zipWriter *zip.Writer
+	sheet     io.Writer
+	rowIndex  int
+}
+
+const (
+	xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/></Types>`
+	xlsxRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`
+	xlsxWorkbook = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="Sheet1" sheetId="1" r:id="rId1"/></sheets></workbook>`
+	xlsxWorkbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/></Relationships>`
+	xlsxSheetHeader = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`
+	xlsxSheetFooter = `</sheetData></worksheet>`
+)
+
+func newXLSXWriter(w io.Writer) (*xlsxWriter, error) {
+	zipWriter := zip.NewWriter(w)
+	parts := []struct {
+		name    string
+		content string
+	}{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRels},
+		{"xl/workbook.xml", xlsxWorkbook},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels},
+	}
+	for _, part := range parts {
+		partWriter, err := zipWriter.Create(part.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err = io.WriteString(partWriter, part.content); err != nil {
+			return nil, err
+		}
+	}
+
+	// The sheet is the last entry, rows are appended to it until Close
+	sheet, err := zipWriter.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	if _, err = io.WriteString(sheet, xlsxSheetHeader); err != nil {
+		return nil, err
+	}
+	return &xlsxWriter{zipWriter: zipWriter, sheet: sheet}, nil
+}
+
+func (x *xlsxWriter) WriteRow(row []string) error {
+	x.rowIndex++
+	var sb strings.Builder
+	sb.WriteString(`<row r="`)
+	sb.WriteString(strconv.Itoa(x.rowIndex))
+	sb.WriteString(`">`)
+	for _, value := range row {
+		if len(value) > xlsxCellLimit {
+			value = value[:xlsxCellLimit]
+		}
+		sb.WriteString(`<c t="inlineStr"><is><t xml:space="preserve">`)
+		_ = xml.EscapeText(&sb, []byte(value))
+		sb.WriteString(`</t></is></c>`)
+	}
+	sb.WriteString(`</row>`)
+	_, err := io.WriteString(x.sheet, sb.String())
+	return err
+}
+
+func (x *xlsxWriter) Flush() error {
+	return x.zipWriter.Flush()
+}
+
+func (x *xlsxWriter) Close() error {
+	if _, err := io.WriteString(x.sheet, xlsxSheetFooter); err != nil {
+		return err
+	}
+	return x.zipWriter.Close()
+}
+
+func (x *xlsxWriter) ContentType() string {
+	contentType, _ := ContentType(FormatXLSX)
+	return contentType
+}
@@ -0,0 +1,155 @@
+package healthcheck
+
+import (
+	"context"
+	"errors"
+	"io"
+	"linkshortener/lib/urlpolicy"
+	"linkshortener/model"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	defaultConcurrency = 8
+	defaultTimeout     = 10 * time.Second
+	maxRedirects       = 5
+	userAgent          = "LLS-HealthCheck/1.0"
+)
+
+// Target A link to check
+type Target struct {
+	Hash string
+	URL  string
+}
+
+// Checker Probes destinations with at most Concurrency requests in flight
+// and at most PerHostRate requests per second to the same host
+type Checker struct {
+	Concurrency int
+	PerHostRate rate.Limit
+	Timeout     time.Duration
+
+	client   *http.Client
+	limiters sync.Map
+}
+
+// NewChecker A checker whose connections are subject to the destination policy, so it cannot be pointed at internal services
+func NewChecker(concurrency int, perHostRate float64, timeout time.Duration) *Checker {
+	if concurrency <= 0 {
+		concurrency = defaultConcurrency
+	}
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	limit := rate.Limit(perHostRate)
+	if perHostRate <= 0 {
+		limit = rate.Inf
+	}
+
+	return &Checker{
+		Concurrency: concurrency,
+		PerHostRate: limit,
+		Timeout:     timeout,
+		client:      urlpolicy.NewHTTPClient(timeout, maxRedirects),
+	}
+}
+
+// Run Check every target and pass the results to record, it returns once all of them are checked or ctx is done.
+// record may be called from several goroutines at once.
+func (c *Checker) Run(ctx context.Context, targets []Target, record func(target Target, health model.LinkHealth)) {
+	queue := make(chan Target)
+	var wg sync.WaitGroup
+	for i := 0; i < c.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for target := range queue {
+				record(target, c.Check(ctx, target.URL))
+			}
+		}()
+	}
+
+	for _, target := range targets {
+		select {
+		case queue <- target:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	close(queue)
+	wg.Wait()
+}
+
+// Check Probe one destination with HEAD, falling back to GET for servers that do not support HEAD
+func (c *Checker) Check(ctx context.Context, rawURL string) model.LinkHealth {
+	health := model.LinkHealth{CheckedAt: time.Now().Unix()}
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") {
+		health.Error = "not an http(s) destination"
+		return health
+	}
+
+	if err = c.limiter(parsedURL.Hostname()).Wait(ctx); err != nil {
+		health.Error = err.Error()
+		return health
+	}
+
+	start := time.Now()
+	status, err := c.probe(ctx, http.MethodHead, rawURL)
+	if err == nil && (status == http.StatusMethodNotAllowed || status == http.StatusNotImplemented) {
+		status, err = c.probe(ctx, http.MethodGet, rawURL)
+	}
+	health.LatencyMs = time.Since(start).Milliseconds()
+	if err != nil {
+		health.Error = errorMessage(err)
+		return health
+	}
+	health.Status = status
+	health.Healthy = status < http.StatusBadRequest
+	return health
+}
+
+func (c *Checker) probe(ctx context.Context, method string, rawURL string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// A little of the body is drained so the connection can be reused
+	_, _ = io.CopyN(io.Discard, resp.Body, 4096)
+	return resp.StatusCode, nil
+}
+
+func (c *Checker) limiter(host string) *rate.Limiter {
+	host = strings.ToLower(host)
+	if limiter, ok := c.limiters.Load(host); ok {
+		return limiter.(*rate.Limiter)
+	}
+	limiter, _ := c.limiters.LoadOrStore(host, rate.NewLimiter(c.PerHostRate, 1))
+	return limiter.(*rate.Limiter)
+}
+
+// errorMessage A short error without the request URL, which is already known
+func errorMessage(err error) string {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		err = urlErr.Err
+	}
+	if errors.Is(err, urlpolicy.ErrAddress) {
+		return urlpolicy.ErrAddress.Error()
+	}
+	return err.Error()
+}
@@ -47,6 +47,10 @@ func (f *fileData) InitIPData(geoip2CityBytes []byte, geoip2IspBytes []byte) (rs
 
 func (f *fileData) Find(ipStr string) (res model.Location) {
 	res = model.Location{}
+	// Nothing can be located before InitIPData
+	if f.CityDB == nil || f.IspDB == nil {
+		return res
+	}
 
 	ip := net.ParseIP(ipStr)
 
@@ -0,0 +1,36 @@
+package page
+
+import (
+	"html/template"
+	"io"
+	"linkshortener/log"
+	"sync"
+)
+
+var (
+	mu        sync.RWMutex
+	templates = make(map[string]*template.Template)
+)
+
+// InitTemplate Parse a server-rendered page, it panics on an invalid template like the other resources
+func InitTemplate(name string, content []byte) {
+	tmpl, err := template.New(name).Parse(string(content))
+	if err != nil {
+		log.PanicPrint("Loading template %s failed: %s", name, err)
+		return
+	}
+	mu.Lock()
+	templates[name] = tmpl
+	mu.Unlock()
+}
+
+// Render Execute the named page with data
+func Render(w io.Writer, name string, data interface{}) error {
+	mu.RLock()
+	tmpl, ok := templates[name]
+	mu.RUnlock()
+	if !ok {
+		return log.Errorf("template %s is not loaded", name)
+	}
+	return tmpl.Execute(w, data)
+}
@@ -0,0 +1,166 @@
+package passhash
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"linkshortener/lib/tool"
+	"linkshortener/log"
+	"linkshortener/setting"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// The argon2id parameters of new hashes, they are recorded in the encoded hash so they can be raised later
+const (
+	algorithm  = "argon2id"
+	memory     = 19 * 1024 // KiB
+	iterations = 2
+	threads    = 1
+	saltLen    = 16
+	keyLen     = 32
+)
+
+// tokenPrefix Marks a manage token stored as a keyed hash, plaintext tokens are alphanumeric
+const tokenPrefix = "$hmac-sha256$"
+
+// tokenKey Keys the manage token hashes, it must stay the same across restarts
+var tokenKey []byte
+
+// InitPasshash Initialize the key of the manage token hashes, see db.TokenHashKey
+func InitPasshash(key string) {
+	if key == "" {
+		log.PanicPrint("The manage token hash key must not be empty")
+	}
+	tokenKey = []byte(key)
+}
+
+// Hash Derive an encoded argon2id hash with a random salt,
+// $argon2id$v=19$m=19456,t=2,p=1$<salt>$<key> in the PHC string format
+func Hash(password string) string {
+	salt := make([]byte, saltLen)
+	_, _ = rand.Read(salt)
+	key := argon2.IDKey([]byte(password), salt, iterations, memory, threads, keyLen)
+	return fmt.Sprintf("$%s$v=%d$m=%d,t=%d,p=%d$%s$%s", algorithm, argon2.Version, memory, iterations, threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))
+}
+
+// Verify Reports whether the password matches the encoded hash.
+// shortHash is the hash of the link, it salts the legacy SHA-256 hashes.
+// rehash is true when the password matched an outdated hash that should be replaced by Hash(password).
+func Verify(encoded string, password string, shortHash string) (ok bool, rehash bool) {
+	if !strings.HasPrefix(encoded, "$") {
+		ok = verifyLegacy(encoded, password, shortHash)
+		return ok, ok
+	}
+
+	var version int
+	var m, t uint32
+	var p uint8
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != algorithm {
+		return false, false
+	}
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false, false
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &m, &t, &p); err != nil || m == 0 || t == 0 || p == 0 {
+		return false, false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, false
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return false, false
+	}
+
+	derived := argon2.IDKey([]byte(password), salt, t, m, p, uint32(len(key)))
+	if subtle.ConstantTimeCompare(key, derived) != 1 {
+		return false, false
+	}
+	return true, m != memory || t != iterations || p != threads || len(salt) != saltLen || len(key) != keyLen
+}
+
+// verifyLegacy Check a hex SHA-256 of hash+password+seed stored before argon2id was introduced
+func verifyLegacy(encoded string, password string, shortHash string) bool {
+	passwordHash := sha256.Sum256([]byte(tool.ConcatStrings(shortHash, password, tool.Uint32ToBase62String(setting.Cfg.Seed))))
+	return subtle.ConstantTimeCompare([]byte(encoded), []byte(hex.EncodeToString(passwordHash[:]))) == 1
+}
+
+// HashToken The keyed hash of a manage token as it is stored with the link
+func HashToken(token string) string {
+	mac := hmac.New(sha256.New, tokenKey)
+	mac.Write([]byte(token))
+	return tool.ConcatStrings(tokenPrefix, base64.RawStdEncoding.EncodeToString(mac.Sum(nil)))
+}
+
+// IsHashedToken Reports whether the stored token is already a keyed hash
+func IsHashedToken(stored string) bool {
+	return strings.HasPrefix(stored, tokenPrefix)
+}
+
+// VerifyToken Reports whether the token matches the stored one in constant time.
+// Plaintext tokens that were not migrated yet are still accepted.
+func VerifyToken(stored string, token string) bool {
+	if token == "" || stored == "" {
+		return false
+	}
+	if !IsHashedToken(stored) {
+		return subtle.ConstantTimeCompare([]byte(stored), []byte(token)) == 1
+	}
+	return subtle.ConstantTimeCompare([]byte(stored), []byte(HashToken(token))) == 1
+}
+
+// tokenCipher AES-256-GCM keyed with a subkey of the token hash key
+func tokenCipher() (cipher.AEAD, error) {
+	mac := hmac.New(sha256.New, tokenKey)
+	mac.Write([]byte("lls-token-encryption"))
+	block, err := aes.NewCipher(mac.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// EncryptToken Encrypt a manage token that has to be handed out again later, e.g. to a retried bulk request
+func EncryptToken(token string) (string, error) {
+	aead, err := tokenCipher()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.RawStdEncoding.EncodeToString(aead.Seal(nonce, nonce, []byte(token), nil)), nil
+}
+
+// DecryptToken Recover a token sealed by EncryptToken, it fails if the token hash key changed in between
+func DecryptToken(encrypted string) (string, error) {
+	aead, err := tokenCipher()
+	if err != nil {
+		return "", err
+	}
+	data, err := base64.RawStdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return "", err
+	}
+	if len(data) < aead.NonceSize() {
+		return "", errors.New("the encrypted token is too short")
+	}
+	token, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], nil)
+	if err != nil {
+		return "", err
+	}
+	return string(token), nil
+}
@@ -0,0 +1,123 @@
+package preview
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"linkshortener/lib/urlpolicy"
+	"linkshortener/model"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+const (
+	maxRedirects   = 5
+	maxTitle       = 200
+	maxDescription = 500
+	userAgent      = "LLS-Preview/1.0"
+)
+
+// Fetch Read the Open Graph, Twitter Card or plain HTML metadata of the destination.
+// At most maxBytes of the page are read, relative image URLs are resolved against the final URL.
+func Fetch(ctx context.Context, rawURL string, timeout time.Duration, maxBytes int64) (model.LinkPreview, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return model.LinkPreview{}, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+
+	resp, err := urlpolicy.NewHTTPClient(timeout, maxRedirects).Do(req)
+	if err != nil {
+		return model.LinkPreview{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		return model.LinkPreview{}, fmt.Errorf("destination answered %d", resp.StatusCode)
+	}
+	if contentType := resp.Header.Get("Content-Type"); contentType != "" && !strings.Contains(contentType, "html") {
+		return model.LinkPreview{}, nil
+	}
+	return Parse(io.LimitReader(resp.Body, maxBytes), resp.Request.URL), nil
+}
+
+// Parse Extract the preview from an HTML document, og: properties win over twitter: names and plain HTML
+func Parse(r io.Reader, base *url.URL) model.LinkPreview {
+	meta := make(map[string]string)
+	var title string
+	inTitle := false
+
+	tokenizer := html.NewTokenizer(r)
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return buildPreview(meta, title, base)
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := tokenizer.Token()
+			switch token.Data {
+			case "meta":
+				var key, content string
+				for _, attr := range token.Attr {
+					switch strings.ToLower(attr.Key) {
+					case "property", "name":
+						if key == "" {
+							key = strings.ToLower(strings.TrimSpace(attr.Val))
+						}
+					case "content":
+						content = strings.TrimSpace(attr.Val)
+					}
+				}
+				if _, exists := meta[key]; key != "" && content != "" && !exists {
+					meta[key] = content
+				}
+			case "title":
+				inTitle = title == ""
+			case "body":
+				// The metadata lives in the head
+				return buildPreview(meta, title, base)
+			}
+		case html.TextToken:
+			if inTitle {
+				title = strings.TrimSpace(string(tokenizer.Text()))
+				inTitle = false
+			}
+		case html.EndTagToken:
+			inTitle = false
+		}
+	}
+}
+
+func buildPreview(meta map[string]string, title string, base *url.URL) model.LinkPreview {
+	preview := model.LinkPreview{
+		Title:       truncate(firstOf(meta["og:title"], meta["twitter:title"], title), maxTitle),
+		Description: truncate(firstOf(meta["og:description"], meta["twitter:description"], meta["description"]), maxDescription),
+	}
+	if image := firstOf(meta["og:image:secure_url"], meta["og:image"], meta["twitter:image"], meta["twitter:image:src"]); image != "" {
+		if imageURL, err := base.Parse(image); err == nil && (imageURL.Scheme == "http" || imageURL.Scheme == "https") {
+			preview.Image = imageURL.String()
+		}
+	}
+	return preview
+}
+
+func firstOf(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// truncate Cut the text to at most n runes
+func truncate(text string, n int) string {
+	if utf8.RuneCountInString(text) <= n {
+		return text
+	}
+	return string([]rune(text)[:n])
+}
@@ -0,0 +1,128 @@
+package privacy
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"linkshortener/lib/tool"
+	"linkshortener/log"
+	"linkshortener/setting"
+	"net"
+	"net/http"
+	"strings"
+)
+
+const (
+	IPModeFull     = "FULL"
+	IPModeTruncate = "TRUNCATE"
+	IPModeHash     = "HASH"
+	IPModeNone     = "NONE"
+)
+
+// sensitiveHeaders These headers are never persisted, regardless of the whitelist
+var sensitiveHeaders = map[string]bool{
+	"Cookie":              true,
+	"Set-Cookie":          true,
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+}
+
+var (
+	ipMode          string
+	ipHashKey       []byte
+	headerWhitelist map[string]bool
+)
+
+// InitPrivacy Initialize the privacy policy used for the access log
+func InitPrivacy() {
+	ipMode = strings.ToUpper(strings.TrimSpace(setting.Cfg.Privacy.IPMode))
+	switch ipMode {
+	case "":
+		ipMode = IPModeFull
+	case IPModeFull, IPModeTruncate, IPModeHash, IPModeNone:
+	default:
+		log.PanicPrint("Privacy IP_MODE is only allowed to be FULL|TRUNCATE|HASH|NONE")
+	}
+
+	if ipMode == IPModeHash {
+		if setting.Cfg.Privacy.IPHashKey != "" {
+			ipHashKey = []byte(setting.Cfg.Privacy.IPHashKey)
+		} else {
+			key, _ := tool.GetToken(32)
+			ipHashKey = []byte(key)
+			log.WarnPrint("IP_HASH_KEY is empty, a random key is used and IP hashes will change after restart")
+		}
+	}
+
+	headerWhitelist = make(map[string]bool)
+	for _, name := range setting.Cfg.Privacy.HeaderWhitelist {
+		name = strings.TrimSpace(name)
+		if name != "" {
+			headerWhitelist[http.CanonicalHeaderKey(name)] = true
+		}
+	}
+}
+
+// OptOut Reports whether the visitor asked not to be tracked via DNT or Sec-GPC
+func OptOut(header http.Header) bool {
+	if !setting.Cfg.Privacy.HonorDNT {
+		return false
+	}
+	return header.Get("DNT") == "1" || header.Get("Sec-GPC") == "1"
+}
+
+// AnonymizeIP Apply the configured IP mode to the client IP before it is stored
+func AnonymizeIP(ipStr string) string {
+	switch ipMode {
+	case IPModeTruncate:
+		return TruncateIP(ipStr, setting.Cfg.Privacy.IPv4PrefixBits, setting.Cfg.Privacy.IPv6PrefixBits)
+	case IPModeHash:
+		return HashIP(ipStr, ipHashKey)
+	case IPModeNone:
+		return ""
+	default:
+		return ipStr
+	}
+}
+
+// TruncateIP Keep only the leading prefix bits of the address, e.g. 203.0.113.7 -> 203.0.113.0 with 24 bits
+func TruncateIP(ipStr string, v4Bits int, v6Bits int) string {
+	ip := net.ParseIP(ipStr)
+	if ip == nil {
+		return ""
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		if v4Bits <= 0 || v4Bits > 32 {
+			v4Bits = 24
+		}
+		return ip4.Mask(net.CIDRMask(v4Bits, 32)).String()
+	}
+	if v6Bits <= 0 || v6Bits > 128 {
+		v6Bits = 48
+	}
+	return ip.Mask(net.CIDRMask(v6Bits, 128)).String()
+}
+
+// HashIP Replace the address with a keyed HMAC-SHA256 so visits can still be told apart without storing the IP
+func HashIP(ipStr string, key []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(ipStr))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// FilterHeader Return a copy of the header containing only the whitelisted entries.
+// If no whitelist is configured every header except the sensitive ones is kept.
+func FilterHeader(header http.Header) http.Header {
+	filtered := http.Header{}
+	for name, values := range header {
+		name = http.CanonicalHeaderKey(name)
+		if sensitiveHeaders[name] {
+			continue
+		}
+		if len(headerWhitelist) > 0 && !headerWhitelist[name] {
+			continue
+		}
+		filtered[name] = append([]string(nil), values...)
+	}
+	return filtered
+}
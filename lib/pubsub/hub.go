@@ -0,0 +1,128 @@
+package pubsub
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrTooManySubscribers The topic already has the maximum number of subscribers
+var ErrTooManySubscribers = errors.New("too many subscribers")
+
+// Hub In-process publish/subscribe hub, messages are delivered to every subscriber of a topic.
+// Publishing never blocks: a subscriber whose buffer is full is dropped and its channel closed.
+type Hub struct {
+	mu          sync.RWMutex
+	topics      map[string]map[*Subscriber]struct{}
+	buffer      int
+	maxPerTopic int
+}
+
+// Subscriber A subscription to one topic of the hub
+type Subscriber struct {
+	topic   string
+	ch      chan interface{}
+	dropped bool
+}
+
+// NewHub Create a hub, buffer is the number of pending messages per subscriber,
+// maxPerTopic limits the subscribers of a topic (0 means unlimited)
+func NewHub(buffer int, maxPerTopic int) *Hub {
+	if buffer <= 0 {
+		buffer = 1
+	}
+	return &Hub{
+		topics:      make(map[string]map[*Subscriber]struct{}),
+		buffer:      buffer,
+		maxPerTopic: maxPerTopic,
+	}
+}
+
+// Subscribe Register a new subscriber of the topic
+func (h *Hub) Subscribe(topic string) (*Subscriber, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	subscribers, ok := h.topics[topic]
+	if !ok {
+		subscribers = make(map[*Subscriber]struct{})
+		h.topics[topic] = subscribers
+	}
+	if h.maxPerTopic > 0 && len(subscribers) >= h.maxPerTopic {
+		return nil, ErrTooManySubscribers
+	}
+
+	subscriber := &Subscriber{
+		topic: topic,
+		ch:    make(chan interface{}, h.buffer),
+	}
+	subscribers[subscriber] = struct{}{}
+	return subscriber, nil
+}
+
+// Unsubscribe Remove the subscriber and close its channel, it is safe to call more than once
+func (h *Hub) Unsubscribe(subscriber *Subscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.remove(subscriber)
+}
+
+// remove must be called with the write lock held, so no Publish is sending on the channel being closed
+func (h *Hub) remove(subscriber *Subscriber) {
+	subscribers, ok := h.topics[subscriber.topic]
+	if !ok {
+		return
+	}
+	if _, ok = subscribers[subscriber]; !ok {
+		return
+	}
+	delete(subscribers, subscriber)
+	close(subscriber.ch)
+	if len(subscribers) == 0 {
+		delete(h.topics, subscriber.topic)
+	}
+}
+
+// Publish Deliver the message to the subscribers of the topic and return how many received it
+func (h *Hub) Publish(topic string, msg interface{}) int {
+	var slow []*Subscriber
+	delivered := 0
+
+	h.mu.RLock()
+	for subscriber := range h.topics[topic] {
+		select {
+		case subscriber.ch <- msg:
+			delivered++
+		default:
+			slow = append(slow, subscriber)
+		}
+	}
+	h.mu.RUnlock()
+
+	if len(slow) > 0 {
+		h.mu.Lock()
+		for _, subscriber := range slow {
+			subscriber.dropped = true
+			h.remove(subscriber)
+		}
+		h.mu.Unlock()
+	}
+	return delivered
+}
+
+// Subscribers The number of subscribers of the topic
+func (h *Hub) Subscribers(topic string) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.topics[topic])
+}
+
+// Messages The channel receiving the published messages, it is closed when the subscriber is removed
+func (s *Subscriber) Messages() <-chan interface{} {
+	return s.ch
+}
+
+// Dropped Reports whether the subscriber was removed because it did not keep up.
+// It is only meaningful after the Messages channel has been closed.
+func (s *Subscriber) Dropped() bool {
+	return s.dropped
+}
@@ -0,0 +1,334 @@
+package qr
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/jpeg"
+	"image/png"
+	"linkshortener/log"
+	"linkshortener/setting"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/golang/freetype/truetype"
+	"github.com/llgcode/draw2d"
+	"github.com/llgcode/draw2d/draw2dimg"
+	"github.com/llgcode/draw2d/draw2dkit"
+	"github.com/skip2/go-qrcode"
+)
+
+const (
+	fontName    = "Arphic Roman-Mincho Ultra JIS"
+	logoRatio   = 0.2 // Share of the code width covered by the logo, level H restores up to 30% of the modules
+	minFontSize = 10
+	minContrast = 2.0
+)
+
+var (
+	ErrColor    = errors.New("invalid color")
+	ErrContrast = errors.New("foreground and background colors do not contrast enough")
+	ErrLevel    = errors.New("invalid error correction level")
+)
+
+// logo The center logo loaded from LOGO_FILE, nil when none is configured
+var logo image.Image
+
+// Options How the code is drawn.
+// Size is the width in pixels including the margin, it is rounded down to a multiple of the module count.
+// Margin is the quiet zone in modules. A logo raises the error correction level to H.
+// The caption is drawn under the code with Font, the SVG output leaves the font choice to the viewer.
+type Options struct {
+	Size       int
+	Level      qrcode.RecoveryLevel
+	Margin     int
+	Foreground color.RGBA
+	Background color.RGBA
+	Logo       image.Image
+	Caption    string
+	Font       *truetype.Font
+}
+
+// InitQR Load the center logo, a broken logo file only disables the logo
+func InitQR() {
+	logo = nil
+	if setting.Cfg.QR.LogoFile == "" {
+		return
+	}
+	img, err := loadLogo(setting.Cfg.QR.LogoFile)
+	if err != nil {
+		log.ErrorPrint("Load QR logo %s failed: %s", setting.Cfg.QR.LogoFile, err)
+		return
+	}
+	logo = img
+}
+
+// Logo The configured center logo, nil when none is configured
+func Logo() image.Image {
+	return logo
+}
+
+func loadLogo(path string) (image.Image, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	img, _, err := image.Decode(file)
+	return img, err
+}
+
+// ParseLevel Parse the error correction level L, M, Q or H
+func ParseLevel(level string) (qrcode.RecoveryLevel, error) {
+	switch strings.ToUpper(level) {
+	case "L":
+		return qrcode.Low, nil
+	case "M", "":
+		return qrcode.Medium, nil
+	case "Q":
+		return qrcode.High, nil
+	case "H":
+		return qrcode.Highest, nil
+	default:
+		return 0, ErrLevel
+	}
+}
+
+// ParseColor Parse a hex color, RGB, RRGGBB or RRGGBBAA with an optional leading #
+func ParseColor(hex string) (color.RGBA, error) {
+	hex = strings.TrimPrefix(hex, "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+	if len(hex) != 8 {
+		return color.RGBA{}, ErrColor
+	}
+	value, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.RGBA{}, ErrColor
+	}
+	return color.RGBA{R: uint8(value >> 24), G: uint8(value >> 16), B: uint8(value >> 8), A: uint8(value)}, nil
+}
+
+// CheckContrast Scanners need a clear difference between the modules and the background
+func CheckContrast(foreground, background color.RGBA) error {
+	if background.A == 0 {
+		return nil
+	}
+	light, dark := luminance(foreground), luminance(background)
+	if light < dark {
+		light, dark = dark, light
+	}
+	if (light+0.05)/(dark+0.05) < minContrast {
+		return ErrContrast
+	}
+	return nil
+}
+
+// luminance Relative luminance as defined by WCAG
+func luminance(c color.RGBA) float64 {
+	channel := func(v uint8) float64 {
+		s := float64(v) / 255
+		if s <= 0.03928 {
+			return s / 12.92
+		}
+		return math.Pow((s+0.055)/1.055, 2.4)
+	}
+	return 0.2126*channel(c.R) + 0.7152*channel(c.G) + 0.0722*channel(c.B)
+}
+
+// encode The module matrix of the content without the quiet zone
+func encode(content string, opts Options) ([][]bool, error) {
+	level := opts.Level
+	if opts.Logo != nil {
+		level = qrcode.Highest
+	}
+	code, err := qrcode.New(content, level)
+	if err != nil {
+		return nil, err
+	}
+	code.DisableBorder = true
+	return code.Bitmap(), nil
+}
+
+// PNG Draw the code as a PNG image
+func PNG(content string, opts Options) ([]byte, error) {
+	bitmap, err := encode(content, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	total := len(bitmap) + 2*opts.Margin
+	module := max(opts.Size/total, 1)
+	side := module * total
+	fontSize, captionHeight := 0.0, 0
+	if opts.Caption != "" && opts.Font != nil {
+		fontSize = max(float64(side)/16, minFontSize)
+		captionHeight = int(fontSize * 2)
+	}
+
+	dest := image.NewRGBA(image.Rect(0, 0, side, side+captionHeight))
+	draw.Draw(dest, dest.Bounds(), image.NewUniform(opts.Background), image.Point{}, draw.Src)
+
+	gc := draw2dimg.NewGraphicContext(dest)
+	gc.SetFillColor(opts.Foreground)
+	for y, row := range bitmap {
+		for x, dark := range row {
+			if dark {
+				x0, y0 := float64((x+opts.Margin)*module), float64((y+opts.Margin)*module)
+				draw2dkit.Rectangle(gc, x0, y0, x0+float64(module), y0+float64(module))
+			}
+		}
+	}
+	gc.Fill()
+
+	if opts.Logo != nil {
+		drawLogo(gc, dest, opts, side)
+	}
+	if captionHeight > 0 {
+		drawCaption(gc, opts, side, fontSize, captionHeight)
+	}
+
+	buf := new(bytes.Buffer)
+	if err = png.Encode(buf, dest); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// drawLogo Scale the logo into the center on a padded background square
+func drawLogo(gc *draw2dimg.GraphicContext, dest *image.RGBA, opts Options, side int) {
+	x, y, w, h, pad := logoBox(float64(side), opts.Logo.Bounds())
+
+	gc.SetFillColor(opts.Background)
+	draw2dkit.Rectangle(gc, x-pad, y-pad, x+w+pad, y+h+pad)
+	gc.Fill()
+
+	bounds := opts.Logo.Bounds()
+	tr := draw2d.NewMatrixFromRects(
+		[4]float64{float64(bounds.Min.X), float64(bounds.Min.Y), float64(bounds.Max.X), float64(bounds.Max.Y)},
+		[4]float64{x, y, x + w, y + h},
+	)
+	draw2dimg.DrawImage(opts.Logo, dest, tr, draw.Over, draw2dimg.BicubicFilter)
+}
+
+// logoBox The position and size of the logo centered in a code of the given width, and the padding around it.
+// The logo keeps its aspect ratio, its longest side is a fixed share of the code.
+func logoBox(side float64, bounds image.Rectangle) (x, y, w, h, pad float64) {
+	box := side * logoRatio
+	w, h = box, box
+	if bounds.Dx() > 0 && bounds.Dy() > 0 {
+		if bounds.Dx() >= bounds.Dy() {
+			h = box * float64(bounds.Dy()) / float64(bounds.Dx())
+		} else {
+			w = box * float64(bounds.Dx()) / float64(bounds.Dy())
+		}
+	}
+	return (side - w) / 2, (side - h) / 2, w, h, box / 10
+}
+
+// drawCaption Center the caption under the code, shrinking the font when it is wider than the code
+func drawCaption(gc *draw2dimg.GraphicContext, opts Options, side int, fontSize float64, captionHeight int) {
+	gc.FontCache = draw2d.NewSyncFolderFontCache("./arphic.ttf")
+	gc.FontCache.Store(draw2d.FontData{Name: fontName, Family: 0, Style: draw2d.FontStyleNormal}, opts.Font)
+	gc.SetFontData(draw2d.FontData{Name: fontName, Style: draw2d.FontStyleNormal})
+	gc.SetDPI(72)
+	gc.SetFontSize(fontSize)
+
+	left, _, right, _ := gc.GetStringBounds(opts.Caption)
+	if width := right - left; width > float64(side)*0.9 {
+		fontSize = max(fontSize*float64(side)*0.9/width, 1)
+		gc.SetFontSize(fontSize)
+		left, _, right, _ = gc.GetStringBounds(opts.Caption)
+	}
+
+	gc.SetFillColor(opts.Foreground)
+	gc.FillStringAt(opts.Caption, (float64(side)-(right-left))/2-left, float64(side)+float64(captionHeight)/2+fontSize/3)
+}
+
+// SVG Draw the code as an SVG document, one unit is one module
+func SVG(content string, opts Options) ([]byte, error) {
+	bitmap, err := encode(content, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	total := len(bitmap) + 2*opts.Margin
+	module := max(float64(opts.Size)/float64(total), 1)
+	captionHeight := 0.0
+	if opts.Caption != "" {
+		captionHeight = max(float64(total)/8, minFontSize/module*2)
+	}
+	height := float64(total) + captionHeight
+
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %s" shape-rendering="crispEdges">`,
+		opts.Size, int(math.Round(height*module)), total, formatFloat(height))
+	fmt.Fprintf(&b, `<rect width="100%%" height="100%%"%s/>`, svgFill(opts.Background))
+
+	b.WriteString(`<path`)
+	b.WriteString(svgFill(opts.Foreground))
+	b.WriteString(` d="`)
+	for y, row := range bitmap {
+		for x, dark := range row {
+			if dark {
+				fmt.Fprintf(&b, "M%d %dh1v1h-1z", x+opts.Margin, y+opts.Margin)
+			}
+		}
+	}
+	b.WriteString(`"/>`)
+
+	if opts.Logo != nil {
+		buf := new(bytes.Buffer)
+		if err = png.Encode(buf, opts.Logo); err != nil {
+			return nil, err
+		}
+		x, y, w, h, pad := logoBox(float64(total), opts.Logo.Bounds())
+		fmt.Fprintf(&b, `<rect x="%s" y="%s" width="%s" height="%s"%s/>`,
+			formatFloat(x-pad), formatFloat(y-pad), formatFloat(w+2*pad), formatFloat(h+2*pad), svgFill(opts.Background))
+		fmt.Fprintf(&b, `<image x="%s" y="%s" width="%s" height="%s" href="data:image/png;base64,%s"/>`,
+			formatFloat(x), formatFloat(y), formatFloat(w), formatFloat(h), base64.StdEncoding.EncodeToString(buf.Bytes()))
+	}
+
+	if opts.Caption != "" {
+		fontSize := captionHeight / 2
+		fmt.Fprintf(&b, `<text x="%s" y="%s" font-family="sans-serif" font-size="%s" text-anchor="middle" dominant-baseline="middle"`,
+			formatFloat(float64(total)/2), formatFloat(float64(total)+captionHeight/2), formatFloat(fontSize))
+		// Glyphs are about half as wide as the font size, a longer caption is squeezed to the width of the code
+		if float64(len([]rune(opts.Caption)))*fontSize/2 > float64(total)*0.9 {
+			fmt.Fprintf(&b, ` textLength="%s" lengthAdjust="spacingAndGlyphs"`, formatFloat(float64(total)*0.9))
+		}
+		b.WriteString(svgFill(opts.Foreground))
+		b.WriteString(`>`)
+		_ = xml.EscapeText(&b, []byte(opts.Caption))
+		b.WriteString(`</text>`)
+	}
+
+	b.WriteString(`</svg>`)
+	return []byte(b.String()), nil
+}
+
+// svgFill The fill attributes of a color, the opacity is only written when it is not opaque
+func svgFill(c color.RGBA) string {
+	fill := fmt.Sprintf(` fill="#%02x%02x%02x"`, c.R, c.G, c.B)
+	if c.A != 255 {
+		fill += fmt.Sprintf(` fill-opacity="%s"`, formatFloat(float64(c.A)/255))
+	}
+	return fill
+}
+
+// formatFloat Three decimals are plenty for coordinates in modules
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(math.Round(f*1000)/1000, 'f', -1, 64)
+}
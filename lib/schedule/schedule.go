@@ -0,0 +1,137 @@
+package schedule
+
+import (
+	"errors"
+	"linkshortener/model"
+	"strings"
+	"sync"
+	"time"
+	_ "time/tzdata" // Named time zones must resolve on hosts without a zoneinfo database
+)
+
+const (
+	timeLayout = "15:04"
+	dateLayout = "2006-01-02"
+)
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+var locations sync.Map
+
+// Destination The URL of the first rule matching now, ok is false when no rule matches
+func Destination(rules []model.ScheduleRule, now time.Time) (url string, ok bool) {
+	for _, rule := range rules {
+		if Match(rule, now) {
+			return rule.URL, true
+		}
+	}
+	return "", false
+}
+
+// Match Reports whether the rule applies at t.
+// A time range whose end is before its start spans midnight, the weekday is the one of the local date at t.
+func Match(rule model.ScheduleRule, t time.Time) bool {
+	location, err := loadLocation(rule.TimeZone)
+	if err != nil {
+		return false
+	}
+	local := t.In(location)
+
+	if len(rule.Weekdays) > 0 {
+		matched := false
+		for _, day := range rule.Weekdays {
+			if weekday, ok := weekdays[strings.ToLower(day)]; ok && weekday == local.Weekday() {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	date := local.Format(dateLayout)
+	if rule.StartDate != "" && date < rule.StartDate {
+		return false
+	}
+	if rule.EndDate != "" && date > rule.EndDate {
+		return false
+	}
+
+	if rule.StartTime != "" || rule.EndTime != "" {
+		minute := local.Hour()*60 + local.Minute()
+		start, end := 0, 24*60
+		if rule.StartTime != "" {
+			start = minuteOfDay(rule.StartTime)
+		}
+		if rule.EndTime != "" {
+			end = minuteOfDay(rule.EndTime)
+		}
+		if start <= end {
+			return minute >= start && minute < end
+		}
+		return minute >= start || minute < end
+	}
+	return true
+}
+
+// Validate Check the rule can be evaluated, it needs at least one condition
+func Validate(rule model.ScheduleRule) error {
+	if len(rule.Weekdays) == 0 && rule.StartTime == "" && rule.EndTime == "" && rule.StartDate == "" && rule.EndDate == "" {
+		return errors.New("no condition")
+	}
+	for _, day := range rule.Weekdays {
+		if _, ok := weekdays[strings.ToLower(day)]; !ok {
+			return errors.New("invalid weekday " + day)
+		}
+	}
+	for _, value := range []string{rule.StartTime, rule.EndTime} {
+		if _, err := time.Parse(timeLayout, value); value != "" && err != nil {
+			return errors.New("invalid time " + value)
+		}
+	}
+	for _, value := range []string{rule.StartDate, rule.EndDate} {
+		if _, err := time.Parse(dateLayout, value); value != "" && err != nil {
+			return errors.New("invalid date " + value)
+		}
+	}
+	if rule.StartDate != "" && rule.EndDate != "" && rule.StartDate > rule.EndDate {
+		return errors.New("start_date is later than end_date")
+	}
+	if _, err := loadLocation(rule.TimeZone); err != nil {
+		return errors.New("invalid time zone " + rule.TimeZone)
+	}
+	return nil
+}
+
+func minuteOfDay(value string) int {
+	parsed, err := time.Parse(timeLayout, value)
+	if err != nil {
+		return 0
+	}
+	return parsed.Hour()*60 + parsed.Minute()
+}
+
+// loadLocation Cache the time zones, time.LoadLocation reads the zoneinfo on every call
+func loadLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+	if location, ok := locations.Load(name); ok {
+		return location.(*time.Location), nil
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	locations.Store(name, location)
+	return location, nil
+}
@@ -1,8 +1,7 @@
 package shorten
 
 import (
-	"crypto/sha256"
-	"encoding/hex"
+	"linkshortener/lib/passhash"
 	"linkshortener/lib/tool"
 	"linkshortener/model"
 	"linkshortener/setting"
@@ -31,9 +30,25 @@ func GenerateShortenLink(req model.InsertLinkReq) model.Link {
 	link.URL = req.URL
 	link.Memo = req.MEMO
 	link.Expire = req.EXPIRE
+	link.ActivateAt = req.ACTIVATE
+	link.Countdown = req.Countdown
+	link.Interstitial = req.Interstitial
+	link.RedirectType = req.RedirectType
+	link.ForwardQuery = req.ForwardQuery
+	link.QueryPrecedence = req.QueryPrecedence
+	link.Wildcard = req.Wildcard
+	link.GeoRules = req.GeoRules
+	link.GeoFallback = req.GeoFallback
+	link.DeviceRules = req.DeviceRules
+	link.ScheduleRules = req.ScheduleRules
+	link.Variants = req.Variants
+	link.StickyVariant = req.StickyVariant
+	link.MaxClicks = req.MaxClicks
+	link.Clicks = 0
+	link.Preview = req.Preview
+	link.Preview.FetchedAt = 0
 	if req.PASSWORD != "" {
-		passwordHash := sha256.Sum256([]byte(tool.ConcatStrings(link.ShortHash, req.PASSWORD, tool.Uint32ToBase62String(setting.Cfg.Seed))))
-		link.Password = hex.EncodeToString(passwordHash[:])
+		link.Password = passhash.Hash(req.PASSWORD)
 	}
 	link.Delete = false
 
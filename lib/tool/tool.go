@@ -15,6 +15,7 @@ import (
 
 	"github.com/gin-contrib/sessions"
 	"github.com/goccy/go-json"
+	"go.mongodb.org/mongo-driver/bson"
 	"golang.org/x/net/idna"
 )
 
@@ -146,19 +147,30 @@ func HTTPAddPrefix(prefix string, h http.Handler) http.Handler {
 	})
 }
 
-// IsDataMatchingFilter checks if data matches the filter conditions
+// IsDataMatchingFilter checks if data matches the filter conditions.
+// A filter value may be an operator document using $eq, $ne, $gt, $gte, $lt, $lte or $in.
 func IsDataMatchingFilter(data, filter map[string]interface{}) bool {
 	// Iterate over key-value pairs in the filter
 	for key, filterValue := range filter {
 		// Check if the data contains the key from the filter
-		dataValue, keyExists := data[key]
+		dataValue, keyExists := lookupMapPath(data, key)
+
+		if operators, ok := toOperatorMap(filterValue); ok {
+			for operator, operand := range operators {
+				if !matchOperator(dataValue, keyExists, operator, operand) {
+					return false
+				}
+			}
+			continue
+		}
+
 		if !keyExists {
 			// Data does not contain the key from the filter, not a match
 			return false
 		}
 
 		// Check if the value in the data equals the value in the filter
-		if dataValue != filterValue {
+		if !valuesEqual(dataValue, filterValue) {
 			// Values do not match, not a match
 			return false
 		}
@@ -168,6 +180,204 @@ func IsDataMatchingFilter(data, filter map[string]interface{}) bool {
 	return true
 }
 
+// lookupMapPath returns the value at the dotted path of data, e.g. "Location.country_iso_code"
+func lookupMapPath(data map[string]interface{}, path string) (interface{}, bool) {
+	if value, exists := data[path]; exists || !strings.Contains(path, ".") {
+		return value, exists
+	}
+	keys := strings.Split(path, ".")
+	current := data
+	for i, key := range keys {
+		value, exists := current[key]
+		if !exists {
+			return nil, false
+		}
+		if i == len(keys)-1 {
+			return value, true
+		}
+		next, isMap := value.(map[string]interface{})
+		if !isMap {
+			return nil, false
+		}
+		current = next
+	}
+	return nil, false
+}
+
+// toOperatorMap returns the filter value as an operator document if all of its keys start with "$"
+func toOperatorMap(filterValue interface{}) (map[string]interface{}, bool) {
+	var operators map[string]interface{}
+	switch v := filterValue.(type) {
+	case bson.M:
+		operators = v
+	case map[string]interface{}:
+		operators = v
+	case bson.D:
+		operators = make(map[string]interface{}, len(v))
+		for _, elem := range v {
+			operators[elem.Key] = elem.Value
+		}
+	default:
+		return nil, false
+	}
+	if len(operators) == 0 {
+		return nil, false
+	}
+	for operator := range operators {
+		if !strings.HasPrefix(operator, "$") {
+			return nil, false
+		}
+	}
+	return operators, true
+}
+
+func matchOperator(dataValue interface{}, keyExists bool, operator string, operand interface{}) bool {
+	switch operator {
+	case "$eq":
+		return keyExists && valuesEqual(dataValue, operand)
+	case "$ne":
+		return !keyExists || !valuesEqual(dataValue, operand)
+	case "$in":
+		if !keyExists {
+			return false
+		}
+		operandValue := reflect.ValueOf(operand)
+		if operandValue.Kind() != reflect.Slice && operandValue.Kind() != reflect.Array {
+			return false
+		}
+		for i := 0; i < operandValue.Len(); i++ {
+			if valuesEqual(dataValue, operandValue.Index(i).Interface()) {
+				return true
+			}
+		}
+		return false
+	case "$gt", "$gte", "$lt", "$lte":
+		if !keyExists {
+			return false
+		}
+		cmp, ok := compareValues(dataValue, operand)
+		if !ok {
+			return false
+		}
+		switch operator {
+		case "$gt":
+			return cmp > 0
+		case "$gte":
+			return cmp >= 0
+		case "$lt":
+			return cmp < 0
+		default:
+			return cmp <= 0
+		}
+	default:
+		return false
+	}
+}
+
+// toFloat64 converts any numeric value to float64, JSON decoded numbers are always float64
+func toFloat64(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int8:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint8:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
+
+func valuesEqual(a, b interface{}) bool {
+	af, aOk := toFloat64(a)
+	bf, bOk := toFloat64(b)
+	if aOk && bOk {
+		return af == bf
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// compareValues returns -1, 0 or 1 for numbers and strings, ok is false if the values are not comparable
+func compareValues(a, b interface{}) (int, bool) {
+	af, aOk := toFloat64(a)
+	bf, bOk := toFloat64(b)
+	if aOk && bOk {
+		switch {
+		case af < bf:
+			return -1, true
+		case af > bf:
+			return 1, true
+		default:
+			return 0, true
+		}
+	}
+	as, aOk := a.(string)
+	bs, bOk := b.(string)
+	if aOk && bOk {
+		return strings.Compare(as, bs), true
+	}
+	return 0, false
+}
+
+// IncMapPath adds delta to the number at the dotted path of data, creating missing nested maps,
+// e.g. "countries.CN" increments data["countries"]["CN"]
+func IncMapPath(data map[string]interface{}, path string, delta interface{}) error {
+	deltaFloat, ok := toFloat64(delta)
+	if !ok {
+		return fmt.Errorf("increment value of %s is not a number", path)
+	}
+	keys := strings.Split(path, ".")
+	current := data
+	for _, key := range keys[:len(keys)-1] {
+		next, exists := current[key]
+		if !exists || next == nil {
+			nextMap := make(map[string]interface{})
+			current[key] = nextMap
+			current = nextMap
+			continue
+		}
+		nextMap, isMap := next.(map[string]interface{})
+		if !isMap {
+			return fmt.Errorf("%s is not a document", key)
+		}
+		current = nextMap
+	}
+
+	lastKey := keys[len(keys)-1]
+	value, exists := current[lastKey]
+	if !exists || value == nil {
+		current[lastKey] = deltaFloat
+		return nil
+	}
+	valueFloat, ok := toFloat64(value)
+	if !ok {
+		return fmt.Errorf("%s is not a number", path)
+	}
+	current[lastKey] = valueFloat + deltaFloat
+	return nil
+}
+
 func processStructFields(val reflect.Value, typ reflect.Type, resultMap map[string]interface{}) error {
 	for i := 0; i < val.NumField(); i++ {
 		fieldValue := val.Field(i)
@@ -228,12 +438,17 @@ func unmarshalStructFields(structValue reflect.Value, resultMap map[string]inter
 						return nil, nestedStructErr
 					}
 
-					for key, value := range nestedStructMap {
-						processedMap[key] = value
+					if fieldType.Anonymous {
+						// The fields of embedded structs are promoted, encoding/json expects them at this level
+						for key, value := range nestedStructMap {
+							processedMap[key] = value
+						}
+					} else {
+						processedMap[jsonFieldName(fieldType)] = nestedStructMap
 					}
 				}
 			} else {
-				processedMap[fieldType.Name] = jsonValue
+				processedMap[jsonFieldName(fieldType)] = jsonValue
 			}
 		}
 	}
@@ -241,6 +456,14 @@ func unmarshalStructFields(structValue reflect.Value, resultMap map[string]inter
 	return processedMap, err
 }
 
+// jsonFieldName The key encoding/json decodes into the field, the name of the json tag or else the field name
+func jsonFieldName(field reflect.StructField) string {
+	if name, _, _ := strings.Cut(field.Tag.Get("json"), ","); name != "" && name != "-" {
+		return name
+	}
+	return field.Name
+}
+
 // MarshalJsonByBson serializes a struct into JSON data based on "bson" tags.
 // It takes a struct and returns the JSON representation as a byte slice.
 func MarshalJsonByBson(i interface{}) ([]byte, error) {
@@ -0,0 +1,254 @@
+package urlpolicy
+
+import (
+	"context"
+	"errors"
+	"linkshortener/lib/domainlist"
+	"linkshortener/log"
+	"linkshortener/setting"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+var (
+	ErrScheme       = errors.New("scheme is not allowed")
+	ErrUserinfo     = errors.New("URL contains credentials")
+	ErrHost         = errors.New("host is not allowed")
+	ErrAddress      = errors.New("host resolves to a restricted address")
+	ErrUnresolvable = errors.New("host does not resolve")
+)
+
+const defaultResolveTimeout = 3 * time.Second
+
+// unsafeSchemes Schemes that are never accepted, they run in the context of the page
+var unsafeSchemes = []string{"javascript", "data", "vbscript", "file", "blob"}
+
+// metadataAddresses Cloud metadata services, they are refused even if private addresses are allowed
+var metadataAddresses = []string{
+	"169.254.169.254/32", // AWS, GCP, Azure, OpenStack
+	"169.254.170.2/32",   // AWS ECS task metadata
+	"100.100.100.200/32", // Alibaba Cloud
+	"fd00:ec2::254/128",  // AWS IPv6
+}
+
+// sharedAddressSpace Carrier-grade NAT (RFC 6598), netip does not count it as private
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+var (
+	allowedSchemes  map[string]bool
+	blockedPrefixes []netip.Prefix
+	blockedHosts    map[string]bool
+	resolveTimeout  time.Duration
+
+	lookup   = net.DefaultResolver.LookupNetIP
+	lookupMu sync.RWMutex
+)
+
+// InitPolicy Initialize the destination policy from app.ini
+func InitPolicy() {
+	cfg := setting.Cfg.Destination
+
+	allowedSchemes = make(map[string]bool)
+	for _, scheme := range cfg.AllowedSchemes {
+		scheme = strings.ToLower(strings.TrimSpace(scheme))
+		if scheme == "" {
+			continue
+		}
+		if IsUnsafeScheme(scheme) {
+			log.PanicPrint("Destination ALLOWED_SCHEMES must not contain %s", scheme)
+		}
+		allowedSchemes[scheme] = true
+	}
+	if len(allowedSchemes) == 0 {
+		allowedSchemes["http"] = true
+		allowedSchemes["https"] = true
+	}
+
+	blockedPrefixes = blockedPrefixes[:0]
+	for _, entry := range append(append([]string{}, metadataAddresses...), cfg.BlockedAddresses...) {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			addr, err := netip.ParseAddr(entry)
+			if err != nil {
+				log.PanicPrint("Destination BLOCKED_ADDRESSES contains an invalid address %s", entry)
+			}
+			blockedPrefixes = append(blockedPrefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			log.PanicPrint("Destination BLOCKED_ADDRESSES contains an invalid CIDR %s", entry)
+		}
+		blockedPrefixes = append(blockedPrefixes, prefix.Masked())
+	}
+
+	blockedHosts = make(map[string]bool)
+	for _, host := range cfg.BlockedHosts {
+		if host = strings.TrimSpace(host); host != "" {
+			blockedHosts[domainlist.NormalizeHost(host)] = true
+		}
+	}
+
+	resolveTimeout = time.Duration(cfg.ResolveTimeout) * time.Second
+	if resolveTimeout <= 0 {
+		resolveTimeout = defaultResolveTimeout
+	}
+}
+
+// SetResolver Replace the DNS lookup used to resolve destination hosts, nil restores the default resolver
+func SetResolver(resolver func(ctx context.Context, network, host string) ([]netip.Addr, error)) {
+	lookupMu.Lock()
+	defer lookupMu.Unlock()
+	if resolver == nil {
+		resolver = net.DefaultResolver.LookupNetIP
+	}
+	lookup = resolver
+}
+
+// Check Reports why the destination may not be shortened, nil if it may.
+// Host names are resolved and every address they resolve to has to pass, unless SKIP_RESOLVE is set.
+func Check(u *url.URL) error {
+	if !SchemeAllowed(u.Scheme) {
+		return ErrScheme
+	}
+	if u.User != nil {
+		return ErrUserinfo
+	}
+	if u.Host == "" {
+		return nil
+	}
+
+	host := domainlist.NormalizeHost(u.Hostname())
+	if host == "" || blockedHosts[host] {
+		return ErrHost
+	}
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if !AddressAllowed(addr) {
+			return ErrAddress
+		}
+		return nil
+	}
+	// 2130706433, 0x7f.1 and 0177.0.0.1 are not IPs for Go, but browsers and libc read them as 127.0.0.1
+	if isNumericHost(host) {
+		return ErrAddress
+	}
+	if setting.Cfg.Destination.SkipResolve {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), resolveTimeout)
+	defer cancel()
+	lookupMu.RLock()
+	resolve := lookup
+	lookupMu.RUnlock()
+	addrs, err := resolve(ctx, "ip", host)
+	if err != nil || len(addrs) == 0 {
+		return ErrUnresolvable
+	}
+	for _, addr := range addrs {
+		if !AddressAllowed(addr) {
+			return ErrAddress
+		}
+	}
+	return nil
+}
+
+// SchemeAllowed Reports whether links may point to the scheme.
+// Without ALLOWED_SCHEMES only http and https are allowed, or every safe scheme if ALLOW_ALL_PROTOCOL is set.
+func SchemeAllowed(scheme string) bool {
+	scheme = strings.ToLower(scheme)
+	if IsUnsafeScheme(scheme) || scheme == "" {
+		return false
+	}
+	if len(setting.Cfg.Destination.AllowedSchemes) == 0 && setting.Cfg.AllowAllProtocol {
+		return true
+	}
+	return allowedSchemes[scheme]
+}
+
+// AddressAllowed Reports whether links may point to the address
+func AddressAllowed(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range blockedPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	if setting.Cfg.Destination.AllowPrivateAddresses {
+		return true
+	}
+	return !(addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast() || addr.IsUnspecified() || sharedAddressSpace.Contains(addr))
+}
+
+// IsUnsafeScheme Reports whether the scheme runs in the context of the page, such as javascript:
+func IsUnsafeScheme(scheme string) bool {
+	for _, unsafe := range unsafeSchemes {
+		if strings.EqualFold(scheme, unsafe) {
+			return true
+		}
+	}
+	return false
+}
+
+// isNumericHost Reports whether every label of the host is a decimal, octal or hex number
+func isNumericHost(host string) bool {
+	for _, label := range strings.Split(host, ".") {
+		if label == "" {
+			return false
+		}
+		digits := label
+		hex := strings.HasPrefix(label, "0x")
+		if hex {
+			digits = label[2:]
+		}
+		for _, r := range digits {
+			if !(r >= '0' && r <= '9') && !(hex && r >= 'a' && r <= 'f') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// NewHTTPClient A client for fetching destinations, its connections are checked against the policy after resolving
+// so neither DNS rebinding nor redirects can reach restricted addresses
+func NewHTTPClient(timeout time.Duration, maxRedirects int) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil || !AddressAllowed(addrPort.Addr()) {
+				return ErrAddress
+			}
+			return nil
+		},
+	}
+	transport := &http.Transport{
+		Proxy:                 nil,
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   timeout,
+		ResponseHeaderTimeout: timeout,
+		MaxIdleConnsPerHost:   1,
+		IdleConnTimeout:       30 * time.Second,
+	}
+	return &http.Client{
+		Transport: transport,
+		Timeout:   timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects || !SchemeAllowed(req.URL.Scheme) || req.URL.User != nil {
+				return http.ErrUseLastResponse
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"linkshortener/db"
+	"linkshortener/lib/tool"
+	"linkshortener/model"
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/go-playground/assert/v2"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestLinkInfoFilter(t *testing.T) {
+	linkInfo := model.LinkInfo{
+		Hash:     "filtered",
+		Location: model.Location{CountryIsoCode: "CN", Country: "China"},
+		UAInfo:   model.UAInfo{Browser: "Chrome", Device: "Apple iPhone"},
+		Created:  1675143659,
+	}
+	filter := map[string]interface{}{
+		"hash":                       "filtered",
+		model.LinkInfoCountryIsoCode: "CN",
+		model.LinkInfoBrowser:        "Chrome",
+		model.LinkInfoDevice:         "Apple iPhone",
+	}
+
+	t.Run("MongoDB", func(t *testing.T) {
+		// The document as the MongoDB driver marshals the struct
+		data, err := bson.Marshal(linkInfo)
+		assert.Equal(t, err, nil)
+		var document bson.M
+		assert.Equal(t, bson.Unmarshal(data, &document), nil)
+		assert.Equal(t, tool.IsDataMatchingFilter(toJSONMap(t, document), filter), true)
+
+		// MongoDBTable.InsertOne converts the document with MarshalJsonByBson first, both shapes must agree
+		converted, err := tool.MarshalJsonByBson(linkInfo)
+		assert.Equal(t, err, nil)
+		var insertedDocument map[string]interface{}
+		assert.Equal(t, json.Unmarshal(converted, &insertedDocument), nil)
+		assert.Equal(t, toJSONMap(t, document), insertedDocument)
+
+		var decoded model.LinkInfo
+		assert.Equal(t, bson.Unmarshal(data, &decoded), nil)
+		assert.Equal(t, decoded, linkInfo)
+
+		filter[model.LinkInfoCountryIsoCode] = "US"
+		assert.Equal(t, tool.IsDataMatchingFilter(toJSONMap(t, document), filter), false)
+		filter[model.LinkInfoCountryIsoCode] = "CN"
+	})
+
+	t.Run("BadgerDB", func(t *testing.T) {
+		badgerDB, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer badgerDB.Close()
+
+		var lls db.LlsBadgerDB
+		table := db.NewBadgerDBTable(lls.SetBadgerDB(badgerDB), "link_access")
+		_, err = table.InsertOne(linkInfo, true, db.Insert().SetKeyPrefix("filtered"))
+		assert.Equal(t, err, nil)
+		other := linkInfo
+		other.Location.CountryIsoCode = "US"
+		_, err = table.InsertOne(other, true, db.Insert().SetKeyPrefix("filtered"))
+		assert.Equal(t, err, nil)
+
+		var res []model.LinkInfo
+		bsonFilter := bson.D{
+			{Key: "hash", Value: "filtered"},
+			{Key: model.LinkInfoCountryIsoCode, Value: "CN"},
+			{Key: model.LinkInfoBrowser, Value: "Chrome"},
+			{Key: model.LinkInfoDevice, Value: "Apple iPhone"},
+		}
+		err = table.Find(bsonFilter, &res, db.Find().SetKey("filtered").SetPrefixScans(true))
+		assert.Equal(t, err, nil)
+		assert.Equal(t, len(res), 1)
+		assert.Equal(t, res[0].Location.CountryIsoCode, "CN")
+	})
+}
+
+// toJSONMap The document with nested bson.M replaced by plain maps, like BadgerDB keeps them
+func toJSONMap(t *testing.T, document bson.M) map[string]interface{} {
+	data, err := json.Marshal(document)
+	assert.Equal(t, err, nil)
+	var result map[string]interface{}
+	assert.Equal(t, json.Unmarshal(data, &result), nil)
+	return result
+}
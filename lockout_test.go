@@ -0,0 +1,132 @@
+package main
+
+import (
+	"linkshortener/db"
+	"linkshortener/lib/passhash"
+	"linkshortener/model"
+	"linkshortener/setting"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestLockoutDuration(t *testing.T) {
+	previous := setting.Cfg.Lockout
+	defer func() { setting.Cfg.Lockout = previous }()
+	setting.Cfg.Lockout.MaxAttempts = 3
+	setting.Cfg.Lockout.BaseLockout = 30
+	setting.Cfg.Lockout.MaxLockout = 300
+
+	assert.Equal(t, db.LockoutDuration(0), time.Duration(0))
+	assert.Equal(t, db.LockoutDuration(2), time.Duration(0))
+	assert.Equal(t, db.LockoutDuration(3), 30*time.Second)
+	assert.Equal(t, db.LockoutDuration(4), 60*time.Second)
+	assert.Equal(t, db.LockoutDuration(5), 120*time.Second)
+	assert.Equal(t, db.LockoutDuration(6), 240*time.Second)
+	assert.Equal(t, db.LockoutDuration(7), 300*time.Second)
+	assert.Equal(t, db.LockoutDuration(100), 300*time.Second)
+}
+
+func TestRecordFailureConcurrent(t *testing.T) {
+	useBadgerDB(t)
+	previous := setting.Cfg.Lockout
+	defer func() { setting.Cfg.Lockout = previous }()
+	setting.Cfg.Lockout = model.LockoutConfig{EnableLockout: true, MaxAttempts: 5, BaseLockout: 30, MaxLockout: 3600}
+
+	// Every parallel failure is counted once, none of them is lost to a conflict
+	now := time.Now()
+	const failures = 20
+	var wg sync.WaitGroup
+	for i := 0; i < failures; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			db.RecordFailure(now, "password:hash:race", "password:ip:192.0.2.1")
+		}()
+	}
+	wg.Wait()
+
+	table := db.SetModel(setting.Cfg.DB.Database, "auth_attempts")
+	for _, subject := range []string{"password:hash:race", "password:ip:192.0.2.1"} {
+		var attempt model.AuthAttempt
+		assert.Equal(t, table.FindByID(subject, &attempt), nil)
+		assert.Equal(t, attempt.Failures, int64(failures))
+		assert.Equal(t, attempt.LockedUntil, now.Add(db.LockoutDuration(failures)).Unix())
+	}
+	assert.Equal(t, db.LockedUntil(now, "password:ip:192.0.2.1"), now.Add(time.Hour).Unix())
+
+	// A success clears the count, the next failure starts again below the limit
+	db.ResetFailures("password:hash:race")
+	assert.Equal(t, db.RecordFailure(now, "password:hash:race"), int64(0))
+}
+
+func TestUpdateByIDIfConcurrent(t *testing.T) {
+	useBadgerDB(t)
+	table := db.SetModel(setting.Cfg.DB.Database, "auth_attempts")
+	_, err := table.InsertOne(model.AuthAttempt{ID: "counter"}, false)
+	assert.Equal(t, err, nil)
+
+	// Every writer reads the count and only writes the next one if nobody changed it in between
+	const writers, increments = 10, 10
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for done := 0; done < increments; {
+				var current model.AuthAttempt
+				if err := table.FindByID("counter", &current); err != nil {
+					t.Error(err)
+					return
+				}
+				updated, err := table.UpdateByIDIf("counter", bson.M{"failures": current.Failures},
+					bson.M{"$set": bson.M{"failures": current.Failures + 1}})
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if updated {
+					done++
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	var attempt model.AuthAttempt
+	assert.Equal(t, table.FindByID("counter", &attempt), nil)
+	assert.Equal(t, attempt.Failures, int64(writers*increments))
+}
+
+func TestPasswordLockoutPerClient(t *testing.T) {
+	router := useRouter(t)
+	setting.Cfg.Lockout = model.LockoutConfig{EnableLockout: true, MaxAttempts: 2, BaseLockout: 60, MaxLockout: 60}
+	insertLink(t, model.Link{ShortHash: "locked", URL: "https://example.com/", Password: passhash.Hash("secret")})
+	insertLink(t, model.Link{ShortHash: "shared", URL: "https://example.com/", Password: passhash.Hash("secret")})
+
+	unlock := func(hash string, ip string, password string) int {
+		req := httptest.NewRequest(http.MethodPost, "/s/"+hash, strings.NewReader(url.Values{"pwd": {password}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.RemoteAddr = ip + ":40000"
+		return serve(router, req).Code
+	}
+
+	// Wrong passwords lock out the guessing client, the owner elsewhere still gets in
+	assert.Equal(t, unlock("locked", "192.0.2.1", "guess"), http.StatusUnauthorized)
+	assert.Equal(t, unlock("locked", "192.0.2.1", "guess"), http.StatusTooManyRequests)
+	assert.Equal(t, unlock("locked", "192.0.2.1", "secret"), http.StatusTooManyRequests)
+	assert.Equal(t, unlock("locked", "198.51.100.7", "secret"), http.StatusSeeOther)
+
+	// PER_LINK locks the link for every client
+	setting.Cfg.Lockout.PerLink = true
+	assert.Equal(t, unlock("shared", "192.0.2.2", "guess"), http.StatusUnauthorized)
+	assert.Equal(t, unlock("shared", "192.0.2.3", "guess"), http.StatusTooManyRequests)
+	assert.Equal(t, unlock("shared", "198.51.100.7", "secret"), http.StatusTooManyRequests)
+}
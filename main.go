@@ -7,6 +7,12 @@ import (
 	"linkshortener/controller"
 	"linkshortener/db"
 	"linkshortener/fs"
+	"linkshortener/lib/blocklist"
+	"linkshortener/lib/domainlist"
+	"linkshortener/lib/passhash"
+	"linkshortener/lib/privacy"
+	"linkshortener/lib/qr"
+	"linkshortener/lib/urlpolicy"
 	"linkshortener/log"
 	"linkshortener/setting"
 	"time"
@@ -20,9 +26,20 @@ func main() {
 	fs.InitUap()
 	fs.InitIPData()
 	fs.InitI18n()
+	fs.InitTemplates()
+	privacy.InitPrivacy()
+	domainlist.InitDomainList()
+	urlpolicy.InitPolicy()
+	qr.InitQR()
 
 	db.InitDB()
 	db.InitModel()
+	passhash.InitPasshash(db.TokenHashKey())
+	db.MigrateTokens()
+	db.InitRetention()
+	db.InitLockout()
+	blocklist.InitBlocklist(db.RecheckLinks)
+	db.InitHealthCheck()
 
 	controller.InitController()
 	controller.InitRouter()
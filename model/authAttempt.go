@@ -0,0 +1,9 @@
+package model
+
+// AuthAttempt Failed password or token checks of one subject (a link hash or a client IP), used for the brute-force lockout
+type AuthAttempt struct {
+	ID          string `bson:"_id"`
+	Failures    int64  `bson:"failures"`
+	LastFailure int64  `bson:"last_failure"`
+	LockedUntil int64  `bson:"locked_until"`
+}
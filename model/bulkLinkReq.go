@@ -0,0 +1,20 @@
+package model
+
+// BulkLinkItem One link of a bulk creation, posted as an element of a JSON array or a CSV row with the same column names
+type BulkLinkItem struct {
+	URL      string `json:"link"   binding:"required,url"`
+	PASSWORD string `json:"pwd"    binding:"omitempty,max=128"`
+	EXPIRE   int64  `json:"expire" binding:"omitempty,numeric"`
+	MEMO     string `json:"memo"   binding:"omitempty,max=32"`
+	Alias    string `json:"alias"  binding:"omitempty,alphanum,min=4,max=32"`
+}
+
+// BulkLinkResult The outcome of one item, in the order of the request
+type BulkLinkResult struct {
+	Index   int    `json:"index"`
+	Success bool   `json:"success"`
+	Hash    string `json:"hash,omitempty"`
+	Token   string `json:"token,omitempty"`
+	Message string `json:"message,omitempty"`
+	Detail  string `json:"detail,omitempty"`
+}
@@ -0,0 +1,14 @@
+package model
+
+// ClickEvent This struct represents a click pushed to the real-time stream of a link
+type ClickEvent struct {
+	Hash           string `json:"hash"`
+	CountryIsoCode string `json:"country_iso_code"`
+	Country        string `json:"country"`
+	Device         string `json:"device"`
+	Browser        string `json:"browser"`
+	OS             string `json:"os"`
+	Referrer       string `json:"referrer"`
+	Variant        string `json:"variant"`
+	Created        int64  `json:"created"`
+}
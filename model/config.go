@@ -6,6 +6,7 @@ type Config struct {
 	RunMode          string `ini:"RUN_MODE"`
 	Seed             uint32 `ini:"GENERATE_SEED"`
 	AllowAllProtocol bool   `ini:"ALLOW_ALL_PROTOCOL"`
+	TokenHashKey     string `ini:"TOKEN_HASH_KEY"`
 
 	LOG         LOGConfig         `ini:"log"`
 	GEOIP2      GEOIP2Config      `ini:"geoip2"`
@@ -15,6 +16,18 @@ type Config struct {
 	DB          DBConfig          `ini:"db"`
 	BadgerDB    BadgerDBConfig    `ini:"badgerdb"`
 	MongoDB     MongoDBConfig     `ini:"mongodb"`
+	Privacy     PrivacyConfig     `ini:"privacy"`
+	Retention   RetentionConfig   `ini:"retention"`
+	Stream      StreamConfig      `ini:"stream"`
+	Redirect    RedirectConfig    `ini:"redirect"`
+	Lockout     LockoutConfig     `ini:"lockout"`
+	DomainList  DomainListConfig  `ini:"domain_list"`
+	Blocklist   BlocklistConfig   `ini:"blocklist"`
+	Destination DestinationConfig `ini:"destination"`
+	HealthCheck HealthCheckConfig `ini:"health_check"`
+	Preview     PreviewConfig     `ini:"preview"`
+	QR          QRConfig          `ini:"qr"`
+	Bulk        BulkConfig        `ini:"bulk"`
 }
 
 type LOGConfig struct {
@@ -42,6 +55,7 @@ type HTTPConfig struct {
 	DisableFilesDirEmbed bool   `ini:"DISABLE_STATIC_FILES_DIR_EMBED"`
 	FilesDirURI          string `ini:"STATIC_FILES_DIR_URI"`
 	LooseCORS            bool   `ini:"LOOSE_CORS"`
+	SecureCookies        bool   `ini:"SECURE_COOKIES"`
 }
 
 type HTTPLimiterConfig struct {
@@ -74,3 +88,102 @@ type MongoDBConfig struct {
 	MaxPoolSize     int      `ini:"MAX_POOL_SIZE"`
 	MaxConnIdleTime int      `ini:"MAX_CONN_IDLE_TIME"`
 }
+
+type PrivacyConfig struct {
+	IPMode          string   `ini:"IP_MODE"`
+	IPv4PrefixBits  int      `ini:"IPV4_PREFIX_BITS"`
+	IPv6PrefixBits  int      `ini:"IPV6_PREFIX_BITS"`
+	IPHashKey       string   `ini:"IP_HASH_KEY"`
+	HeaderWhitelist []string `ini:"HEADER_WHITELIST"`
+	HonorDNT        bool     `ini:"HONOR_DNT"`
+}
+
+type RetentionConfig struct {
+	AccessLogDays int  `ini:"ACCESS_LOG_DAYS"`
+	SweepInterval int  `ini:"SWEEP_INTERVAL"`
+	KeepRollups   bool `ini:"KEEP_ROLLUPS"`
+}
+
+type StreamConfig struct {
+	EnableStream      bool `ini:"ENABLE_STREAM"`
+	SubscriberBuffer  int  `ini:"SUBSCRIBER_BUFFER"`
+	MaxSubscribers    int  `ini:"MAX_SUBSCRIBERS_PER_LINK"`
+	HeartbeatInterval int  `ini:"HEARTBEAT_INTERVAL"`
+}
+
+type RedirectConfig struct {
+	DefaultRedirectType     int    `ini:"DEFAULT_REDIRECT_TYPE"`
+	PermanentRedirectMaxAge int    `ini:"PERMANENT_REDIRECT_MAX_AGE"`
+	QueryPrecedence         string `ini:"QUERY_PRECEDENCE"`
+	StickyVariantMaxAge     int    `ini:"STICKY_VARIANT_MAX_AGE"`
+	InterstitialMode        string `ini:"INTERSTITIAL_MODE"`
+	PasswordPage            bool   `ini:"PASSWORD_PAGE"`
+	UnlockMaxAge            int    `ini:"UNLOCK_MAX_AGE"`
+}
+
+type LockoutConfig struct {
+	EnableLockout bool `ini:"ENABLE_LOCKOUT"`
+	MaxAttempts   int  `ini:"MAX_ATTEMPTS"`
+	BaseLockout   int  `ini:"BASE_LOCKOUT"`
+	MaxLockout    int  `ini:"MAX_LOCKOUT"`
+	ResetAfter    int  `ini:"RESET_AFTER"`
+	PerLink       bool `ini:"PER_LINK"`
+}
+
+type DomainListConfig struct {
+	AllowListFile  string `ini:"ALLOW_LIST_FILE"`
+	DenyListFile   string `ini:"DENY_LIST_FILE"`
+	ReloadInterval int    `ini:"RELOAD_INTERVAL"`
+}
+
+type BlocklistConfig struct {
+	EnableBlocklist bool   `ini:"ENABLE_BLOCKLIST"`
+	FeedDir         string `ini:"FEED_DIR"`
+	ReloadInterval  int    `ini:"RELOAD_INTERVAL"`
+	RecheckLinks    bool   `ini:"RECHECK_LINKS"`
+}
+
+type DestinationConfig struct {
+	AllowedSchemes        []string `ini:"ALLOWED_SCHEMES"`
+	AllowPrivateAddresses bool     `ini:"ALLOW_PRIVATE_ADDRESSES"`
+	BlockedAddresses      []string `ini:"BLOCKED_ADDRESSES"`
+	BlockedHosts          []string `ini:"BLOCKED_HOSTS"`
+	SkipResolve           bool     `ini:"SKIP_RESOLVE"`
+	ResolveTimeout        int      `ini:"RESOLVE_TIMEOUT"`
+}
+
+type HealthCheckConfig struct {
+	EnableHealthCheck bool    `ini:"ENABLE_HEALTH_CHECK"`
+	Interval          int     `ini:"INTERVAL"`
+	Concurrency       int     `ini:"CONCURRENCY"`
+	PerHostRate       float64 `ini:"PER_HOST_RATE"`
+	Timeout           int     `ini:"TIMEOUT"`
+}
+
+type PreviewConfig struct {
+	EnablePreview bool     `ini:"ENABLE_PREVIEW"`
+	BaseURL       string   `ini:"BASE_URL"`
+	FetchPreview  bool     `ini:"FETCH_PREVIEW"`
+	FetchTimeout  int      `ini:"FETCH_TIMEOUT"`
+	MaxFetchSize  int64    `ini:"MAX_FETCH_SIZE"`
+	CacheTTL      int      `ini:"CACHE_TTL"`
+	CrawlerAgents []string `ini:"CRAWLER_AGENTS"`
+}
+
+type QRConfig struct {
+	EnableQR    bool   `ini:"ENABLE_QR"`
+	BaseURL     string `ini:"BASE_URL"`
+	DefaultSize int    `ini:"DEFAULT_SIZE"`
+	MaxSize     int    `ini:"MAX_SIZE"`
+	Margin      int    `ini:"MARGIN"`
+	LogoFile    string `ini:"LOGO_FILE"`
+	CacheMaxAge int    `ini:"CACHE_MAX_AGE"`
+}
+
+type BulkConfig struct {
+	EnableBulk     bool     `ini:"ENABLE_BULK"`
+	APIKeys        []string `ini:"API_KEYS"`
+	MaxBatchSize   int      `ini:"MAX_BATCH_SIZE"`
+	Concurrency    int      `ini:"CONCURRENCY"`
+	IdempotencyTTL int      `ini:"IDEMPOTENCY_TTL"`
+}
@@ -0,0 +1,12 @@
+package model
+
+// DeviceRule This struct represents a device targeted destination of a link.
+// Every non-empty condition has to match the visitor UA for the rule to apply.
+// The URL may be an app deep link, Fallback is the web page offered when the app is not installed.
+type DeviceRule struct {
+	OS       string `json:"os"       bson:"os"       binding:"omitempty,max=64"`
+	Device   string `json:"device"   bson:"device"   binding:"omitempty,max=64"`
+	Browser  string `json:"browser"  bson:"browser"  binding:"omitempty,max=64"`
+	URL      string `json:"url"      bson:"url"      binding:"required,max=2048"`
+	Fallback string `json:"fallback" bson:"fallback" binding:"omitempty,url"`
+}
@@ -0,0 +1,10 @@
+package model
+
+// GeoRule This struct represents a geo-targeted destination of a link.
+// Every non-empty condition has to match the visitor location for the rule to apply.
+type GeoRule struct {
+	CountryIsoCode         string `json:"country_iso_code"         bson:"country_iso_code"         binding:"omitempty,alpha,len=2"`
+	City                   string `json:"city"                     bson:"city"                     binding:"omitempty,max=64"`
+	AutonomousSystemNumber uint   `json:"autonomous_system_number" bson:"autonomous_system_number" binding:"omitempty,numeric"`
+	URL                    string `json:"url"                      bson:"url"                      binding:"required,url"`
+}
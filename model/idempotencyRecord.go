@@ -0,0 +1,10 @@
+package model
+
+// IdempotencyRecord A bulk request seen with an Idempotency-Key, the response is replayed when the same request is retried
+type IdempotencyRecord struct {
+	ID          string `bson:"_id"`
+	RequestHash string `bson:"request_hash"`
+	Done        bool   `bson:"done"`
+	Response    string `bson:"response"`
+	Created     int64  `bson:"created"`
+}
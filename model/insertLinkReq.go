@@ -2,9 +2,32 @@ package model
 
 // InsertLinkReq This struct represents the payload to be posted to the shortener link
 type InsertLinkReq struct {
-	URL      string `json:"link"    binding:"required,url"`
-	CAPTCHA  string `json:"captcha" binding:"required,alphanum,max=6"`
-	PASSWORD string `json:"pwd"     binding:"omitempty,alphanum,max=8"`
-	EXPIRE   int64  `json:"expire"  binding:"omitempty,numeric"`
-	MEMO     string `json:"memo"    binding:"omitempty,max=32"`
+	URL      string `json:"link"        binding:"required,url"`
+	CAPTCHA  string `json:"captcha"     binding:"required,alphanum,max=6"`
+	PASSWORD string `json:"pwd"         binding:"omitempty,max=128"`
+	EXPIRE   int64  `json:"expire"      binding:"omitempty,numeric"`
+	ACTIVATE int64  `json:"activate_at" binding:"omitempty,numeric"`
+	MEMO     string `json:"memo"        binding:"omitempty,max=32"`
+
+	RedirectType    int    `json:"redirect_type"    binding:"omitempty,oneof=301 302 307 308"`
+	ForwardQuery    bool   `json:"forward_query"    binding:"omitempty"`
+	QueryPrecedence string `json:"query_precedence" binding:"omitempty,oneof=incoming target"`
+	Wildcard        bool   `json:"wildcard"         binding:"omitempty"`
+
+	GeoRules    []GeoRule `json:"geo_rules"    binding:"omitempty,max=32,dive"`
+	GeoFallback string    `json:"geo_fallback" binding:"omitempty,url"`
+
+	DeviceRules []DeviceRule `json:"device_rules" binding:"omitempty,max=32,dive"`
+
+	ScheduleRules []ScheduleRule `json:"schedule_rules" binding:"omitempty,max=32,dive"`
+
+	Variants      []Variant `json:"variants"       binding:"omitempty,max=16,dive"`
+	StickyVariant bool      `json:"sticky_variant" binding:"omitempty"`
+
+	Countdown    bool `json:"countdown"    binding:"omitempty"`
+	Interstitial bool `json:"interstitial" binding:"omitempty"`
+
+	MaxClicks int64 `json:"max_clicks" binding:"omitempty,min=0"`
+
+	Preview LinkPreview `json:"preview"`
 }
@@ -2,12 +2,43 @@ package model
 
 // Link This Struct representing the data to be stored
 type Link struct {
-	ShortHash string `bson:"_id"`
-	URL       string `bson:"url"`
-	Password  string `bson:"password"`
-	Token     string `bson:"token"`
-	Created   int64  `bson:"created"`
-	Expire    int64  `bson:"expire"`
-	Memo      string `bson:"memo"`
-	Delete    bool   `bson:"delete"`
+	ShortHash  string `bson:"_id"`
+	URL        string `bson:"url"`
+	Password   string `bson:"password"`
+	Token      string `bson:"token"`
+	Created    int64  `bson:"created"`
+	Expire     int64  `bson:"expire"`
+	ActivateAt int64  `bson:"activate_at"`
+	Memo       string `bson:"memo"`
+	Delete     bool   `bson:"delete"`
+
+	RedirectType    int    `bson:"redirect_type"`
+	ForwardQuery    bool   `bson:"forward_query"`
+	QueryPrecedence string `bson:"query_precedence"`
+	Wildcard        bool   `bson:"wildcard"`
+
+	GeoRules    []GeoRule `bson:"geo_rules"`
+	GeoFallback string    `bson:"geo_fallback"`
+
+	DeviceRules []DeviceRule `bson:"device_rules"`
+
+	ScheduleRules []ScheduleRule `bson:"schedule_rules"`
+
+	Variants      []Variant `bson:"variants"`
+	StickyVariant bool      `bson:"sticky_variant"`
+
+	Countdown    bool `bson:"countdown"`
+	Interstitial bool `bson:"interstitial"`
+
+	MaxClicks int64 `bson:"max_clicks"`
+	Clicks    int64 `bson:"clicks"`
+
+	Blocked       bool   `bson:"blocked"`
+	BlockedReason string `bson:"blocked_reason"`
+	BlockedAt     int64  `bson:"blocked_at"`
+
+	Health LinkHealth `bson:"health"`
+
+	Preview      LinkPreview `bson:"preview"`
+	PreviewCache LinkPreview `bson:"preview_cache"`
 }
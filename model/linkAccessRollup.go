@@ -0,0 +1,14 @@
+package model
+
+// LinkAccessRollup Daily aggregated access counters of a link, kept after the raw access log has expired
+type LinkAccessRollup struct {
+	ID        string           `bson:"_id"`
+	Hash      string           `bson:"hash"`
+	Day       string           `bson:"day"`
+	Clicks    int64            `bson:"clicks"`
+	Countries map[string]int64 `bson:"countries"`
+	Browsers  map[string]int64 `bson:"browsers"`
+	OS        map[string]int64 `bson:"os"`
+	Devices   map[string]int64 `bson:"devices"`
+	Variants  map[string]int64 `bson:"variants"`
+}
@@ -0,0 +1,10 @@
+package model
+
+// LinkHealth The result of the last health check of the link destination
+type LinkHealth struct {
+	Healthy   bool   `json:"healthy"    bson:"healthy"`
+	Status    int    `json:"status"     bson:"status"`
+	LatencyMs int64  `json:"latency_ms" bson:"latency_ms"`
+	CheckedAt int64  `json:"checked_at" bson:"checked_at"`
+	Error     string `json:"error"      bson:"error"`
+}
@@ -2,13 +2,24 @@ package model
 
 import "net/http"
 
+// Filter paths of the embedded location and UA fields of LinkInfo
+const (
+	LinkInfoCountryIsoCode = "Location.country_iso_code"
+	LinkInfoBrowser        = "UAInfo.browser"
+	LinkInfoDevice         = "UAInfo.device"
+)
+
+// LinkInfo An access record of a link.
+// The embedded structs are tagged with the names BadgerDB and the MongoDB insert path have always stored,
+// without the tags the MongoDB driver would marshal them as "location" and "uainfo".
 type LinkInfo struct {
-	Hash   string      `bson:"hash"`
-	IP     string      `bson:"ip"`
-	Header http.Header `bson:"header"`
-	Location
-	UAInfo
-	Created int64 `bson:"created"`
+	Hash     string      `bson:"hash"`
+	IP       string      `bson:"ip"`
+	Header   http.Header `bson:"header"`
+	Location `bson:"Location"`
+	UAInfo   `bson:"UAInfo"`
+	Variant  string `bson:"variant"`
+	Created  int64  `bson:"created"`
 }
 
 type UAInfo struct {
@@ -0,0 +1,9 @@
+package model
+
+// LinkPreview The Open Graph data shown when the link is unfurled by chat apps and social networks
+type LinkPreview struct {
+	Title       string `json:"title"       bson:"title"       binding:"omitempty,max=200"`
+	Description string `json:"description" bson:"description" binding:"omitempty,max=500"`
+	Image       string `json:"image"       bson:"image"       binding:"omitempty,url,max=2048"`
+	FetchedAt   int64  `json:"fetched_at"  bson:"fetched_at"`
+}
@@ -6,4 +6,12 @@ type ManageLinkReq struct {
 	Token   string `json:"token" binding:"required,alphanum"`
 	Page    int64  `json:"page" binding:"numeric"`
 	Size    int64  `json:"size" binding:"numeric"`
+	From    int64  `json:"from" binding:"omitempty,numeric,min=0"`
+	To      int64  `json:"to" binding:"omitempty,numeric,min=0"`
+	Sort    string `json:"sort" binding:"omitempty,oneof=asc desc"`
+	Country string `json:"country" binding:"omitempty,alpha,len=2"`
+	Browser string `json:"browser" binding:"omitempty,max=64"`
+	Device  string `json:"device" binding:"omitempty,max=64"`
+	Variant string `json:"variant" binding:"omitempty,alphanum,max=32"`
+	Format  string `json:"format" binding:"omitempty,oneof=csv xlsx"`
 }
@@ -0,0 +1,13 @@
+package model
+
+type QRCodeReq struct {
+	Hash       string `uri:"hash"        binding:"required,alphanum"`
+	Format     string `form:"format"     binding:"omitempty,oneof=png svg"`
+	Size       int    `form:"size"       binding:"omitempty,min=64"`
+	Level      string `form:"level"      binding:"omitempty,oneof=L M Q H l m q h"`
+	Margin     *int   `form:"margin"     binding:"omitempty,min=0,max=16"`
+	Foreground string `form:"fg"         binding:"omitempty,max=9"`
+	Background string `form:"bg"         binding:"omitempty,max=9"`
+	Logo       bool   `form:"logo"       binding:"omitempty"`
+	Caption    bool   `form:"caption"    binding:"omitempty"`
+}
@@ -2,7 +2,8 @@ package model
 
 type RedirectLinkReq struct {
 	Hash     string `uri:"hash"     binding:"required,alphanum"`
-	Password string `form:"pwd"     binding:"omitempty,alphanum,max=8"`
+	Path     string `uri:"path"     binding:"omitempty"`
+	Password string `form:"pwd"     binding:"omitempty,max=128"`
 	Soft     bool   `form:"soft"    binding:"omitempty"`
 	Detect   bool   `form:"detect"  binding:"omitempty"`
 }
@@ -0,0 +1,13 @@
+package model
+
+// ScheduleRule This struct represents a destination of a link selected by the time of the visit.
+// Every non-empty condition has to match, times and dates are read in TimeZone (UTC when empty).
+type ScheduleRule struct {
+	Weekdays  []string `json:"weekdays"   bson:"weekdays"   binding:"omitempty,max=7,dive,oneof=mon tue wed thu fri sat sun"`
+	StartTime string   `json:"start_time" bson:"start_time" binding:"omitempty,datetime=15:04"`
+	EndTime   string   `json:"end_time"   bson:"end_time"   binding:"omitempty,datetime=15:04"`
+	StartDate string   `json:"start_date" bson:"start_date" binding:"omitempty,datetime=2006-01-02"`
+	EndDate   string   `json:"end_date"   bson:"end_date"   binding:"omitempty,datetime=2006-01-02"`
+	TimeZone  string   `json:"time_zone"  bson:"time_zone"  binding:"omitempty,max=64"`
+	URL       string   `json:"url"        bson:"url"        binding:"required,url"`
+}
@@ -0,0 +1,8 @@
+package model
+
+// ServerSecret A random secret generated on the first run and kept in the database, so it survives restarts
+type ServerSecret struct {
+	ID      string `bson:"_id"`
+	Value   string `bson:"value"`
+	Created int64  `bson:"created"`
+}
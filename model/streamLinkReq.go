@@ -0,0 +1,7 @@
+package model
+
+type StreamLinkReq struct {
+	Hash    string `uri:"hash"       binding:"required,alphanum"`
+	CAPTCHA string `form:"captcha"   binding:"required,alphanum"`
+	Token   string `form:"token"     binding:"omitempty,alphanum"`
+}
@@ -0,0 +1,9 @@
+package model
+
+// UpdateDeviceRulesReq This struct represents the payload to replace the device targeted rules of a link
+type UpdateDeviceRulesReq struct {
+	Hash        string       `json:"hash"         binding:"required,alphanum"`
+	CAPTCHA     string       `json:"captcha"      binding:"required,alphanum"`
+	Token       string       `json:"token"        binding:"required,alphanum"`
+	DeviceRules []DeviceRule `json:"device_rules" binding:"omitempty,max=32,dive"`
+}
@@ -0,0 +1,10 @@
+package model
+
+// UpdateGeoRulesReq This struct represents the payload to replace the geo-targeted rules of a link
+type UpdateGeoRulesReq struct {
+	Hash        string    `json:"hash"         binding:"required,alphanum"`
+	CAPTCHA     string    `json:"captcha"      binding:"required,alphanum"`
+	Token       string    `json:"token"        binding:"required,alphanum"`
+	GeoRules    []GeoRule `json:"geo_rules"    binding:"omitempty,max=32,dive"`
+	GeoFallback string    `json:"geo_fallback" binding:"omitempty,url"`
+}
@@ -0,0 +1,8 @@
+package model
+
+// Variant This struct represents one of the weighted destinations a link rotates between
+type Variant struct {
+	Name   string `json:"name"   bson:"name"   binding:"required,alphanum,max=32"`
+	URL    string `json:"url"    bson:"url"    binding:"required,url"`
+	Weight int    `json:"weight" bson:"weight" binding:"required,min=1,max=10000"`
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"linkshortener/controller"
+	"linkshortener/db"
+	"linkshortener/lib/passhash"
+	"linkshortener/lib/tool"
+	"linkshortener/model"
+	"linkshortener/setting"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+	"golang.org/x/crypto/argon2"
+)
+
+func TestPasshash(t *testing.T) {
+	t.Run("Argon2id", func(t *testing.T) {
+		encoded := passhash.Hash("correct horse battery staple")
+		assert.Equal(t, strings.HasPrefix(encoded, "$argon2id$v=19$m=19456,t=2,p=1$"), true)
+		assert.NotEqual(t, encoded, passhash.Hash("correct horse battery staple"))
+
+		ok, rehash := passhash.Verify(encoded, "correct horse battery staple", "18nfqL")
+		assert.Equal(t, ok, true)
+		assert.Equal(t, rehash, false)
+		ok, _ = passhash.Verify(encoded, "correct horse battery stapler", "18nfqL")
+		assert.Equal(t, ok, false)
+	})
+
+	t.Run("Outdated Parameters", func(t *testing.T) {
+		salt := []byte("0123456789abcdef")
+		key := argon2.IDKey([]byte("secret"), salt, 1, 8*1024, 1, 32)
+		encoded := fmt.Sprintf("$argon2id$v=19$m=8192,t=1,p=1$%s$%s", base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))
+
+		ok, rehash := passhash.Verify(encoded, "secret", "18nfqL")
+		assert.Equal(t, ok, true)
+		assert.Equal(t, rehash, true)
+		ok, rehash = passhash.Verify("$argon2id$v=19$m=8192,t=1,p=1$bad", "secret", "18nfqL")
+		assert.Equal(t, ok, false)
+		assert.Equal(t, rehash, false)
+	})
+
+	t.Run("Legacy SHA-256", func(t *testing.T) {
+		legacy := sha256.Sum256([]byte(tool.ConcatStrings("18nfqL", "abc123", tool.Uint32ToBase62String(setting.Cfg.Seed))))
+		encoded := hex.EncodeToString(legacy[:])
+
+		ok, rehash := passhash.Verify(encoded, "abc123", "18nfqL")
+		assert.Equal(t, ok, true)
+		assert.Equal(t, rehash, true)
+		ok, rehash = passhash.Verify(encoded, "abc124", "18nfqL")
+		assert.Equal(t, ok, false)
+		assert.Equal(t, rehash, false)
+	})
+
+	t.Run("Manage Token", func(t *testing.T) {
+		passhash.InitPasshash("test-key")
+		stored := passhash.HashToken("IKmXKMrVtBOvdibt")
+		assert.Equal(t, passhash.IsHashedToken(stored), true)
+		assert.Equal(t, strings.Contains(stored, "IKmXKMrVtBOvdibt"), false)
+		assert.Equal(t, passhash.VerifyToken(stored, "IKmXKMrVtBOvdibt"), true)
+		assert.Equal(t, passhash.VerifyToken(stored, "IKmXKMrVtBOvdibT"), false)
+		assert.Equal(t, passhash.VerifyToken(stored, ""), false)
+
+		// Tokens stored before the migration
+		assert.Equal(t, passhash.IsHashedToken("IKmXKMrVtBOvdibt"), false)
+		assert.Equal(t, passhash.VerifyToken("IKmXKMrVtBOvdibt", "IKmXKMrVtBOvdibt"), true)
+	})
+}
+
+func TestTokenHashKey(t *testing.T) {
+	useRouter(t)
+	setting.Cfg.TokenHashKey = ""
+
+	// The generated key is stored, the next start uses the same one
+	key := db.TokenHashKey()
+	assert.Equal(t, len(key), 64)
+	assert.Equal(t, db.TokenHashKey(), key)
+
+	setting.Cfg.TokenHashKey = "configured-key"
+	assert.Equal(t, db.TokenHashKey(), "configured-key")
+}
+
+func TestMigrateTokens(t *testing.T) {
+	useRouter(t)
+	passhash.InitPasshash("test-key")
+	insertLink(t, model.Link{ShortHash: "legacy", URL: "https://example.com/", Token: "IKmXKMrVtBOvdibt"})
+
+	db.MigrateTokens()
+	var link model.Link
+	assert.Equal(t, db.SetModel(setting.Cfg.DB.Database, "links").FindByID("legacy", &link), nil)
+	assert.Equal(t, link.Token, passhash.HashToken("IKmXKMrVtBOvdibt"))
+	assert.Equal(t, passhash.VerifyToken(link.Token, "IKmXKMrVtBOvdibt"), true)
+	assert.Equal(t, passhash.VerifyToken(link.Token, link.Token), false)
+}
+
+func TestRotateToken(t *testing.T) {
+	router := captchaRouter(t)
+	router.POST("/api/rotate_token", controller.RotateToken)
+	passhash.InitPasshash("test-key")
+	insertLink(t, model.Link{ShortHash: "rotate", URL: "https://example.com/", Token: passhash.HashToken("oldToken")})
+
+	rotate := func(token string) *httptest.ResponseRecorder {
+		return postWithCaptcha(router, "/api/rotate_token", fmt.Sprintf(`{"hash":"rotate","captcha":%q,"token":%q}`, testCaptcha, token))
+	}
+
+	response := rotate("oldToken")
+	assert.Equal(t, response.Code, http.StatusOK)
+	var result struct {
+		Data struct {
+			Token string `json:"token"`
+		} `json:"data"`
+	}
+	assert.Equal(t, json.Unmarshal(response.Body.Bytes(), &result), nil)
+	assert.NotEqual(t, result.Data.Token, "")
+
+	// The old token no longer manages the link, the new one does
+	assert.Equal(t, rotate("oldToken").Code, http.StatusForbidden)
+	assert.Equal(t, rotate(result.Data.Token).Code, http.StatusOK)
+}
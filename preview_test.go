@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"linkshortener/db"
+	"linkshortener/lib/preview"
+	"linkshortener/lib/uap"
+	"linkshortener/lib/urlpolicy"
+	"linkshortener/model"
+	"linkshortener/setting"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+)
+
+func TestPreview(t *testing.T) {
+	t.Run("Parse", func(t *testing.T) {
+		base, _ := url.Parse("https://example.com/blog/post")
+		document := `<!DOCTYPE html><html><head>
+<title>Plain Title</title>
+<meta name="description" content="Plain description">
+<meta property="og:title" content="Open Graph Title">
+<meta name="twitter:title" content="Twitter Title">
+<meta name="twitter:image" content="/images/cover.png">
+</head><body><meta property="og:description" content="Ignored after the head"></body></html>`
+
+		linkPreview := preview.Parse(strings.NewReader(document), base)
+		assert.Equal(t, linkPreview.Title, "Open Graph Title")
+		assert.Equal(t, linkPreview.Description, "Plain description")
+		assert.Equal(t, linkPreview.Image, "https://example.com/images/cover.png")
+
+		linkPreview = preview.Parse(strings.NewReader(`<title>Only &amp; Title</title><meta property="og:image" content="javascript:alert(1)">`), base)
+		assert.Equal(t, linkPreview.Title, "Only & Title")
+		assert.Equal(t, linkPreview.Image, "")
+	})
+
+	t.Run("Fetch", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/missing" {
+				http.NotFound(w, r)
+				return
+			}
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			_, _ = w.Write([]byte(`<html><head><meta property="og:title" content="Launch"><meta property="og:image" content="cover.jpg"></head></html>`))
+		}))
+		defer server.Close()
+
+		previous := setting.Cfg.Destination
+		defer func() {
+			setting.Cfg.Destination = previous
+			urlpolicy.InitPolicy()
+		}()
+
+		setting.Cfg.Destination = model.DestinationConfig{}
+		urlpolicy.InitPolicy()
+		_, err := preview.Fetch(context.Background(), server.URL+"/launch/", time.Second, 1024)
+		assert.NotEqual(t, err, nil)
+
+		// The test server listens on the loopback interface
+		setting.Cfg.Destination = model.DestinationConfig{AllowPrivateAddresses: true}
+		urlpolicy.InitPolicy()
+		linkPreview, err := preview.Fetch(context.Background(), server.URL+"/launch/", time.Second, 1024)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, linkPreview.Title, "Launch")
+		assert.Equal(t, linkPreview.Image, server.URL+"/launch/cover.jpg")
+
+		_, err = preview.Fetch(context.Background(), server.URL+"/missing", time.Second, 1024)
+		assert.NotEqual(t, err, nil)
+	})
+
+	t.Run("Crawler Detection", func(t *testing.T) {
+		data, err := os.ReadFile("static/resources/uaparser.yaml")
+		if err != nil {
+			t.Fatal(err)
+		}
+		uap.InitUap(data)
+		parse := func(userAgent string) model.UAInfo {
+			return uap.Parse(http.Header{"User-Agent": []string{userAgent}})
+		}
+
+		assert.Equal(t, parse("Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)").Device, "Spider")
+		assert.Equal(t, parse("facebookexternalhit/1.1 (+http://www.facebook.com/externalhit_uatext.php)").Device, "Spider")
+		assert.Equal(t, parse("Mozilla/5.0 (compatible; Discordbot/2.0; +https://discordapp.com)").Device, "Spider")
+		assert.Equal(t, parse("Twitterbot/1.0").Device, "Spider")
+		assert.NotEqual(t, parse("Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0 Safari/537.36").Device, "Spider")
+	})
+}
+
+func TestPreviewCache(t *testing.T) {
+	var fetches atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write([]byte(`<html><head><meta property="og:title" content="Fetched Title"><meta property="og:description" content="Fetched description"></head></html>`))
+	}))
+	defer server.Close()
+
+	router := useRouter(t)
+	previous := setting.Cfg.Destination
+	defer func() {
+		setting.Cfg.Destination = previous
+		urlpolicy.InitPolicy()
+	}()
+	setting.Cfg.Destination = model.DestinationConfig{AllowPrivateAddresses: true}
+	urlpolicy.InitPolicy()
+	setting.Cfg.Preview = model.PreviewConfig{EnablePreview: true, FetchPreview: true, BaseURL: "https://s.example.com/"}
+	insertLink(t, model.Link{ShortHash: "unfurl", URL: server.URL, Preview: model.LinkPreview{Title: "Admin Title"}})
+
+	crawl := func() string {
+		req := httptest.NewRequest(http.MethodGet, "/s/unfurl", nil)
+		req.Host = "spoofed.example.net"
+		req.Header.Set("User-Agent", "Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)")
+		response := serve(router, req)
+		assert.Equal(t, response.Code, http.StatusOK)
+		return response.Body.String()
+	}
+
+	// The title set with the link wins, the missing description is fetched from the destination and cached
+	body := crawl()
+	assert.Equal(t, strings.Contains(body, `<meta property="og:title" content="Admin Title">`), true)
+	assert.Equal(t, strings.Contains(body, `<meta property="og:description" content="Fetched description">`), true)
+	assert.Equal(t, strings.Contains(body, `<meta property="og:url" content="https://s.example.com/s/unfurl">`), true)
+	assert.Equal(t, strings.Contains(body, "spoofed"), false)
+	assert.Equal(t, fetches.Load(), int64(1))
+
+	var link model.Link
+	assert.Equal(t, db.SetModel(setting.Cfg.DB.Database, "links").FindByID("unfurl", &link), nil)
+	assert.Equal(t, link.Preview.Title, "Admin Title")
+	assert.Equal(t, link.PreviewCache.Title, "Fetched Title")
+	assert.NotEqual(t, link.PreviewCache.FetchedAt, int64(0))
+
+	// The second crawler is served from the cache
+	assert.Equal(t, crawl(), body)
+	assert.Equal(t, fetches.Load(), int64(1))
+
+	// Without a configured origin og:url is left out instead of trusting the Host header
+	setting.Cfg.Preview.BaseURL = ""
+	assert.Equal(t, strings.Contains(crawl(), "og:url"), false)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"linkshortener/lib/privacy"
+	"linkshortener/model"
+	"linkshortener/setting"
+	"net/http"
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+)
+
+func TestPrivacy(t *testing.T) {
+	t.Run("TruncateIP", func(t *testing.T) {
+		assert.Equal(t, privacy.TruncateIP("203.0.113.77", 24, 48), "203.0.113.0")
+		assert.Equal(t, privacy.TruncateIP("203.0.113.77", 16, 48), "203.0.0.0")
+		assert.Equal(t, privacy.TruncateIP("2001:db8:abcd:12::1", 24, 48), "2001:db8:abcd::")
+		assert.Equal(t, privacy.TruncateIP("not an ip", 24, 48), "")
+	})
+
+	t.Run("HashIP", func(t *testing.T) {
+		assert.Equal(t, privacy.HashIP("203.0.113.77", []byte("key")), privacy.HashIP("203.0.113.77", []byte("key")))
+		assert.NotEqual(t, privacy.HashIP("203.0.113.77", []byte("key")), privacy.HashIP("203.0.113.77", []byte("other")))
+	})
+
+	t.Run("FilterHeader", func(t *testing.T) {
+		header := http.Header{}
+		header.Set("User-Agent", "Mozilla/5.0")
+		header.Set("Referer", "https://example.com/")
+		header.Set("Cookie", "session=secret")
+		header.Set("Authorization", "Bearer secret")
+
+		setting.Cfg.Privacy = model.PrivacyConfig{}
+		privacy.InitPrivacy()
+		filtered := privacy.FilterHeader(header)
+		assert.Equal(t, filtered.Get("User-Agent"), "Mozilla/5.0")
+		assert.Equal(t, filtered.Get("Referer"), "https://example.com/")
+		assert.Equal(t, filtered.Get("Cookie"), "")
+		assert.Equal(t, filtered.Get("Authorization"), "")
+
+		setting.Cfg.Privacy.HeaderWhitelist = []string{"user-agent", "Cookie"}
+		privacy.InitPrivacy()
+		filtered = privacy.FilterHeader(header)
+		assert.Equal(t, len(filtered), 1)
+		assert.Equal(t, filtered.Get("User-Agent"), "Mozilla/5.0")
+	})
+
+	t.Run("OptOut", func(t *testing.T) {
+		header := http.Header{}
+		header.Set("Sec-GPC", "1")
+
+		setting.Cfg.Privacy.HonorDNT = false
+		assert.Equal(t, privacy.OptOut(header), false)
+		setting.Cfg.Privacy.HonorDNT = true
+		assert.Equal(t, privacy.OptOut(header), true)
+	})
+}
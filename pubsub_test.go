@@ -0,0 +1,69 @@
+package main
+
+import (
+	"linkshortener/lib/pubsub"
+	"sync"
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+)
+
+func TestPubSubHub(t *testing.T) {
+	t.Run("Publish To Subscribers", func(t *testing.T) {
+		hub := pubsub.NewHub(4, 0)
+		first, _ := hub.Subscribe("abc")
+		second, _ := hub.Subscribe("abc")
+		other, _ := hub.Subscribe("xyz")
+
+		assert.Equal(t, hub.Publish("abc", "click"), 2)
+		assert.Equal(t, <-first.Messages(), "click")
+		assert.Equal(t, <-second.Messages(), "click")
+		assert.Equal(t, len(other.Messages()), 0)
+
+		hub.Unsubscribe(first)
+		hub.Unsubscribe(first)
+		assert.Equal(t, hub.Subscribers("abc"), 1)
+	})
+
+	t.Run("Max Subscribers", func(t *testing.T) {
+		hub := pubsub.NewHub(4, 1)
+		_, err := hub.Subscribe("abc")
+		assert.Equal(t, err, nil)
+		_, err = hub.Subscribe("abc")
+		assert.Equal(t, err, pubsub.ErrTooManySubscribers)
+	})
+
+	t.Run("Drop Slow Subscriber", func(t *testing.T) {
+		hub := pubsub.NewHub(2, 0)
+		slow, _ := hub.Subscribe("abc")
+		for i := 0; i < 3; i++ {
+			hub.Publish("abc", i)
+		}
+		received := 0
+		for range slow.Messages() {
+			received++
+		}
+		assert.Equal(t, received, 2)
+		assert.Equal(t, slow.Dropped(), true)
+		assert.Equal(t, hub.Subscribers("abc"), 0)
+	})
+
+	t.Run("Concurrent Publish And Unsubscribe", func(t *testing.T) {
+		hub := pubsub.NewHub(1, 0)
+		var wg sync.WaitGroup
+		for i := 0; i < 50; i++ {
+			subscriber, _ := hub.Subscribe("abc")
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				hub.Publish("abc", "click")
+			}()
+			go func() {
+				defer wg.Done()
+				hub.Unsubscribe(subscriber)
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, hub.Subscribers("abc"), 0)
+	})
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"linkshortener/lib/qr"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+	"github.com/golang/freetype"
+	"github.com/skip2/go-qrcode"
+)
+
+func TestQRCode(t *testing.T) {
+	const content = "https://s.example.com/s/4nGHqG"
+	black := color.RGBA{A: 255}
+	white := color.RGBA{R: 255, G: 255, B: 255, A: 255}
+
+	t.Run("Parse", func(t *testing.T) {
+		c, err := qr.ParseColor("#1a2b3c")
+		assert.Equal(t, err, nil)
+		assert.Equal(t, c, color.RGBA{R: 0x1a, G: 0x2b, B: 0x3c, A: 0xff})
+		c, _ = qr.ParseColor("f80")
+		assert.Equal(t, c, color.RGBA{R: 0xff, G: 0x88, B: 0x00, A: 0xff})
+		c, _ = qr.ParseColor("00000080")
+		assert.Equal(t, c.A, uint8(0x80))
+		_, err = qr.ParseColor("zzzzzz")
+		assert.Equal(t, err, qr.ErrColor)
+
+		level, _ := qr.ParseLevel("h")
+		assert.Equal(t, level, qrcode.Highest)
+		_, err = qr.ParseLevel("X")
+		assert.Equal(t, err, qr.ErrLevel)
+
+		assert.Equal(t, qr.CheckContrast(black, white), nil)
+		assert.Equal(t, qr.CheckContrast(white, black), nil)
+		assert.Equal(t, qr.CheckContrast(color.RGBA{R: 200, G: 200, B: 200, A: 255}, white), qr.ErrContrast)
+	})
+
+	t.Run("PNG", func(t *testing.T) {
+		data, err := qr.PNG(content, qr.Options{Size: 300, Level: qrcode.Medium, Margin: 4, Foreground: black, Background: white})
+		assert.Equal(t, err, nil)
+		img, err := png.Decode(bytes.NewReader(data))
+		assert.Equal(t, err, nil)
+
+		// Version 3 has 29 modules, 37 with the quiet zone, so a module is 8 pixels
+		assert.Equal(t, img.Bounds(), image.Rect(0, 0, 296, 296))
+		assert.Equal(t, color.RGBAModel.Convert(img.At(4, 4)), white)
+		// Top left corner of the finder pattern
+		assert.Equal(t, color.RGBAModel.Convert(img.At(4*8+4, 4*8+4)), black)
+	})
+
+	t.Run("Logo", func(t *testing.T) {
+		logo := image.NewRGBA(image.Rect(0, 0, 20, 10))
+		for x := 0; x < 20; x++ {
+			for y := 0; y < 10; y++ {
+				logo.Set(x, y, color.RGBA{R: 255, A: 255})
+			}
+		}
+		data, err := qr.PNG(content, qr.Options{Size: 300, Margin: 4, Foreground: black, Background: white, Logo: logo})
+		assert.Equal(t, err, nil)
+		img, _ := png.Decode(bytes.NewReader(data))
+
+		// The logo raises the level to H, which needs a larger version
+		assert.Equal(t, img.Bounds().Dx() > 0, true)
+		center := img.Bounds().Dx() / 2
+		assert.Equal(t, color.RGBAModel.Convert(img.At(center, center)), color.RGBA{R: 255, A: 255})
+	})
+
+	t.Run("Caption", func(t *testing.T) {
+		fontBytes, err := os.ReadFile("static/resources/arphic.ttf")
+		assert.Equal(t, err, nil)
+		font, err := freetype.ParseFont(fontBytes)
+		assert.Equal(t, err, nil)
+
+		data, err := qr.PNG(content, qr.Options{Size: 300, Margin: 4, Foreground: black, Background: white, Caption: "s.example.com/s/4nGHqG", Font: font})
+		assert.Equal(t, err, nil)
+		img, _ := png.Decode(bytes.NewReader(data))
+		assert.Equal(t, img.Bounds().Dy() > img.Bounds().Dx(), true)
+
+		dark := false
+		for x := 0; x < img.Bounds().Dx() && !dark; x++ {
+			for y := img.Bounds().Dx(); y < img.Bounds().Dy(); y++ {
+				if r, _, _, _ := img.At(x, y).RGBA(); r < 0x8000 {
+					dark = true
+					break
+				}
+			}
+		}
+		assert.Equal(t, dark, true)
+	})
+
+	t.Run("SVG", func(t *testing.T) {
+		data, err := qr.SVG(content, qr.Options{Size: 256, Level: qrcode.Medium, Margin: 2, Foreground: color.RGBA{R: 0x1a, G: 0x1a, B: 0x1a, A: 255}, Background: white, Caption: "<a&b>"})
+		assert.Equal(t, err, nil)
+		svg := string(data)
+		assert.Equal(t, strings.HasPrefix(svg, "<?xml"), true)
+		assert.Equal(t, strings.Contains(svg, `viewBox="0 0 33 `), true)
+		// Top left corner of the finder pattern after the two module margin
+		assert.Equal(t, strings.Contains(svg, `fill="#1a1a1a" d="M2 2h1v1h-1z`), true)
+		assert.Equal(t, strings.Contains(svg, "&lt;a&amp;b&gt;</text>"), true)
+		assert.Equal(t, strings.HasSuffix(svg, "</svg>"), true)
+	})
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"linkshortener/model"
+	"linkshortener/setting"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+)
+
+func TestPermanentRedirectCache(t *testing.T) {
+	router := useRouter(t)
+	insertLink(t, model.Link{ShortHash: "moved", URL: "https://example.com/moved", RedirectType: http.StatusMovedPermanently})
+	insertLink(t, model.Link{ShortHash: "perm", URL: "https://example.com/perm", RedirectType: http.StatusPermanentRedirect})
+	insertLink(t, model.Link{ShortHash: "temp", URL: "https://example.com/temp"})
+
+	// Browsers keep permanent redirects forever unless told otherwise
+	setting.Cfg.Redirect.PermanentRedirectMaxAge = 600
+	response := visit(router, httptest.NewRequest(http.MethodGet, "/s/moved", nil))
+	assert.Equal(t, response.Code, http.StatusMovedPermanently)
+	assert.Equal(t, response.Header().Get("Location"), "https://example.com/moved")
+	assert.Equal(t, response.Header().Get("Cache-Control"), "private, max-age=600")
+
+	setting.Cfg.Redirect.PermanentRedirectMaxAge = 0
+	response = visit(router, httptest.NewRequest(http.MethodGet, "/s/perm", nil))
+	assert.Equal(t, response.Code, http.StatusPermanentRedirect)
+	assert.Equal(t, response.Header().Get("Location"), "https://example.com/perm")
+	assert.Equal(t, response.Header().Get("Cache-Control"), "no-cache")
+
+	// Temporary redirects are never cached, so they need no bound
+	response = visit(router, httptest.NewRequest(http.MethodGet, "/s/temp", nil))
+	assert.Equal(t, response.Code, http.StatusTemporaryRedirect)
+	assert.Equal(t, response.Header().Get("Cache-Control"), "")
+}
+
+func TestWildcardPassThrough(t *testing.T) {
+	router := useRouter(t)
+	setting.Cfg.Redirect.QueryPrecedence = "target"
+	insertLink(t, model.Link{ShortHash: "docs", URL: "https://example.com/docs/?lang=en&ref=lls", Wildcard: true, ForwardQuery: true})
+	insertLink(t, model.Link{ShortHash: "override", URL: "https://example.com/docs/?lang=en", Wildcard: true, ForwardQuery: true, QueryPrecedence: "incoming"})
+
+	// The remaining path is appended, the query is merged without the parameters LLS consumes itself
+	response := visit(router, httptest.NewRequest(http.MethodGet, "/s/docs/guide/intro?lang=ja&utm_source=mail&soft=false", nil))
+	assert.Equal(t, response.Code, http.StatusTemporaryRedirect)
+	assert.Equal(t, response.Header().Get("Location"), "https://example.com/docs/guide/intro?lang=en&ref=lls&utm_source=mail")
+
+	// The link may let the incoming values win over the ones of the destination
+	response = visit(router, httptest.NewRequest(http.MethodGet, "/s/override/guide?lang=ja", nil))
+	assert.Equal(t, response.Header().Get("Location"), "https://example.com/docs/guide?lang=ja")
+}
+
+func TestNotYetActive(t *testing.T) {
+	router := useRouter(t)
+	activateAt := time.Now().Add(time.Hour).Unix()
+	insertLink(t, model.Link{ShortHash: "early", URL: "https://example.com/launch", ActivateAt: activateAt})
+	insertLink(t, model.Link{ShortHash: "launched", URL: "https://example.com/launch", ActivateAt: time.Now().Add(-time.Hour).Unix()})
+
+	// The detect mode tells the countdown page when to come back
+	response := visit(router, httptest.NewRequest(http.MethodGet, "/s/early?detect=true", nil))
+	assert.Equal(t, response.Code, http.StatusTooEarly)
+	var result model.Response
+	assert.Equal(t, json.Unmarshal(response.Body.Bytes(), &result), nil)
+	assert.Equal(t, result.Code, http.StatusTooEarly)
+	assert.Equal(t, result.Detail, strconv.FormatInt(activateAt, 10))
+	retryAfter, err := strconv.ParseInt(response.Header().Get("Retry-After"), 10, 64)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, retryAfter > 3500 && retryAfter <= 3600, true)
+
+	response = visit(router, httptest.NewRequest(http.MethodGet, "/s/launched?detect=true", nil))
+	assert.Equal(t, response.Code, http.StatusOK)
+	assert.Equal(t, response.Header().Get("Retry-After"), "")
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"linkshortener/controller"
+	"linkshortener/db"
+	"linkshortener/lib/passhash"
+	"linkshortener/model"
+	"linkshortener/setting"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+)
+
+func TestAccessLogSweep(t *testing.T) {
+	router := captchaRouter(t)
+	router.POST("/api/stats_link", controller.StatsLink)
+	setting.Cfg.Retention.AccessLogDays = 30
+	passhash.InitPasshash("test-key")
+	insertLink(t, model.Link{ShortHash: "retained", URL: "https://example.com/", Token: passhash.HashToken("token")})
+
+	// Rows written before the retention was enabled have no TTL, the sweeper removes them
+	now := time.Now()
+	old := now.AddDate(0, 0, -40).Unix()
+	recent := now.AddDate(0, 0, -1).Unix()
+	insertAccess(t, model.LinkInfo{Hash: "retained", Created: old})
+	insertAccess(t, model.LinkInfo{Hash: "retained", Created: recent})
+
+	removed, err := db.SweepAccessLog()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, removed, int64(1))
+
+	response := postWithCaptcha(router, "/api/stats_link", fmt.Sprintf(`{"hash":"retained","captcha":%q,"token":"token","page":1,"size":10}`, testCaptcha))
+	assert.Equal(t, response.Code, http.StatusOK)
+	var result struct {
+		Data struct {
+			Total         int64 `json:"total"`
+			RetentionDays int   `json:"retention_days"`
+			RetainedSince int64 `json:"retained_since"`
+			Records       []struct {
+				Created int64 `json:"created"`
+			} `json:"records"`
+		} `json:"data"`
+	}
+	assert.Equal(t, json.Unmarshal(response.Body.Bytes(), &result), nil)
+	assert.Equal(t, result.Data.Total, int64(1))
+	assert.Equal(t, result.Data.Records[0].Created, recent)
+	assert.Equal(t, result.Data.RetentionDays, 30)
+	assert.Equal(t, result.Data.RetainedSince > old, true)
+}
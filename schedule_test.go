@@ -0,0 +1,63 @@
+package main
+
+import (
+	"linkshortener/lib/schedule"
+	"linkshortener/model"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+)
+
+func TestSchedule(t *testing.T) {
+	officeHours := model.ScheduleRule{
+		Weekdays:  []string{"mon", "tue", "wed", "thu", "fri"},
+		StartTime: "09:00",
+		EndTime:   "18:00",
+		TimeZone:  "Asia/Shanghai",
+		URL:       "https://example.com/chat",
+	}
+
+	t.Run("Office Hours", func(t *testing.T) {
+		// 2024-03-04 is a Monday, 01:30 UTC is 09:30 in Shanghai
+		assert.Equal(t, schedule.Match(officeHours, time.Date(2024, 3, 4, 1, 30, 0, 0, time.UTC)), true)
+		assert.Equal(t, schedule.Match(officeHours, time.Date(2024, 3, 4, 0, 59, 0, 0, time.UTC)), false)
+		assert.Equal(t, schedule.Match(officeHours, time.Date(2024, 3, 4, 10, 0, 0, 0, time.UTC)), false)
+		// Saturday in Shanghai
+		assert.Equal(t, schedule.Match(officeHours, time.Date(2024, 3, 9, 3, 0, 0, 0, time.UTC)), false)
+	})
+
+	t.Run("Overnight Range", func(t *testing.T) {
+		night := model.ScheduleRule{StartTime: "22:00", EndTime: "06:00", URL: "https://example.com/night"}
+		assert.Equal(t, schedule.Match(night, time.Date(2024, 3, 4, 23, 0, 0, 0, time.UTC)), true)
+		assert.Equal(t, schedule.Match(night, time.Date(2024, 3, 5, 5, 59, 0, 0, time.UTC)), true)
+		assert.Equal(t, schedule.Match(night, time.Date(2024, 3, 5, 6, 0, 0, 0, time.UTC)), false)
+	})
+
+	t.Run("Date Range", func(t *testing.T) {
+		sale := model.ScheduleRule{StartDate: "2024-11-11", EndDate: "2024-11-12", TimeZone: "America/New_York", URL: "https://example.com/sale"}
+		assert.Equal(t, schedule.Match(sale, time.Date(2024, 11, 11, 4, 0, 0, 0, time.UTC)), false)
+		assert.Equal(t, schedule.Match(sale, time.Date(2024, 11, 11, 6, 0, 0, 0, time.UTC)), true)
+		assert.Equal(t, schedule.Match(sale, time.Date(2024, 11, 13, 4, 59, 0, 0, time.UTC)), true)
+		assert.Equal(t, schedule.Match(sale, time.Date(2024, 11, 13, 5, 0, 0, 0, time.UTC)), false)
+	})
+
+	t.Run("Destination", func(t *testing.T) {
+		rules := []model.ScheduleRule{officeHours}
+
+		url, ok := schedule.Destination(rules, time.Date(2024, 3, 4, 2, 0, 0, 0, time.UTC))
+		assert.Equal(t, ok, true)
+		assert.Equal(t, url, "https://example.com/chat")
+
+		_, ok = schedule.Destination(rules, time.Date(2024, 3, 4, 12, 0, 0, 0, time.UTC))
+		assert.Equal(t, ok, false)
+	})
+
+	t.Run("Validate", func(t *testing.T) {
+		assert.Equal(t, schedule.Validate(officeHours), nil)
+		assert.NotEqual(t, schedule.Validate(model.ScheduleRule{URL: "https://example.com"}), nil)
+		assert.NotEqual(t, schedule.Validate(model.ScheduleRule{StartTime: "25:00"}), nil)
+		assert.NotEqual(t, schedule.Validate(model.ScheduleRule{StartDate: "2024-02-01", EndDate: "2024-01-01"}), nil)
+		assert.NotEqual(t, schedule.Validate(model.ScheduleRule{Weekdays: []string{"mon"}, TimeZone: "Mars/Olympus"}), nil)
+	})
+}
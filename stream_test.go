@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"linkshortener/controller"
+	"linkshortener/lib/passhash"
+	"linkshortener/model"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+)
+
+func TestStreamLinkClick(t *testing.T) {
+	router := captchaRouter(t)
+	router.GET("/api/stream_link/:hash", controller.StreamLink)
+	router.GET("/s/:hash", controller.Redirect)
+	passhash.InitPasshash("test-key")
+	insertLink(t, model.Link{ShortHash: "live", URL: "https://example.com/", Token: passhash.HashToken("token")})
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	jar, _ := cookiejar.New(nil)
+	client := &http.Client{Jar: jar, CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	captcha, err := client.Get(server.URL + "/captcha")
+	assert.Equal(t, err, nil)
+	_ = captcha.Body.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/api/stream_link/live?captcha="+testCaptcha, nil)
+	req.Header.Set("Authorization", "Bearer token")
+	stream, err := client.Do(req)
+	assert.Equal(t, err, nil)
+	defer stream.Body.Close()
+	assert.Equal(t, stream.StatusCode, http.StatusOK)
+	assert.Equal(t, strings.HasPrefix(stream.Header.Get("Content-Type"), "text/event-stream"), true)
+
+	events := bufio.NewReader(stream.Body)
+	next := func() (event string, data string) {
+		for {
+			line, err := events.ReadString('\n')
+			if err != nil {
+				t.Fatal(err)
+			}
+			line = strings.TrimSpace(line)
+			switch {
+			case strings.HasPrefix(line, "event:"):
+				event = strings.TrimPrefix(line, "event:")
+			case strings.HasPrefix(line, "data:"):
+				data = strings.TrimPrefix(line, "data:")
+			case line == "" && event != "":
+				return event, data
+			}
+		}
+	}
+
+	// The subscription is confirmed before the click, so the click cannot be missed
+	event, _ := next()
+	assert.Equal(t, event, "ready")
+
+	visit, err := client.Get(server.URL + "/s/live")
+	assert.Equal(t, err, nil)
+	_ = visit.Body.Close()
+	assert.Equal(t, visit.StatusCode, http.StatusTemporaryRedirect)
+
+	event, data := next()
+	assert.Equal(t, event, "click")
+	var click model.ClickEvent
+	assert.Equal(t, json.Unmarshal([]byte(data), &click), nil)
+	assert.Equal(t, click.Hash, "live")
+	assert.NotEqual(t, click.Created, int64(0))
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"linkshortener/lib/passhash"
+	"linkshortener/model"
+	"linkshortener/setting"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+)
+
+// unlockCookie An unlock cookie signed like the server does, the test router signs with testSessionSecret
+func unlockCookie(key string, hash string, passwordHash string, expires int64) *http.Cookie {
+	expiresStr := strconv.FormatInt(expires, 10)
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte("unlock:" + hash + ":" + expiresStr + ":" + passwordHash))
+	return &http.Cookie{Name: "lls_unlock_" + hash, Value: expiresStr + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))}
+}
+
+// postPassword Submit the password page of the link
+func postPassword(router http.Handler, target string, password string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(url.Values{"pwd": {password}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return serve(router, req)
+}
+
+func TestUnlockCookieIssued(t *testing.T) {
+	router := useRouter(t)
+	setting.Cfg.Redirect.UnlockMaxAge = 120
+	insertLink(t, model.Link{ShortHash: "locked", URL: "https://example.com/secret", Password: passhash.Hash("secret")})
+
+	// The right password is exchanged for a cookie scoped to the link and the visitor is sent back
+	response := postPassword(router, "/s/locked", "secret")
+	assert.Equal(t, response.Code, http.StatusSeeOther)
+	assert.Equal(t, response.Header().Get("Location"), "/s/locked")
+	cookies := response.Result().Cookies()
+	assert.Equal(t, len(cookies), 1)
+	cookie := cookies[0]
+	assert.Equal(t, cookie.Name, "lls_unlock_locked")
+	assert.Equal(t, cookie.Path, "/s/locked")
+	assert.Equal(t, cookie.MaxAge, 120)
+	assert.Equal(t, cookie.HttpOnly, true)
+	assert.Equal(t, cookie.Secure, false)
+	assert.Equal(t, cookie.SameSite, http.SameSiteLaxMode)
+
+	expiresStr, _, _ := strings.Cut(cookie.Value, ".")
+	expires, err := strconv.ParseInt(expiresStr, 10, 64)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, expires > time.Now().Unix()+100 && expires <= time.Now().Unix()+120, true)
+
+	req := httptest.NewRequest(http.MethodGet, "/s/locked", nil)
+	req.AddCookie(cookie)
+	response = visit(router, req)
+	assert.Equal(t, response.Code, http.StatusTemporaryRedirect)
+	assert.Equal(t, response.Header().Get("Location"), "https://example.com/secret")
+}
+
+func TestUnlockCookieSecure(t *testing.T) {
+	router := useRouter(t)
+	insertLink(t, model.Link{ShortHash: "locked", URL: "https://example.com/secret", Password: passhash.Hash("secret")})
+
+	// A request over TLS gets a Secure cookie by itself
+	response := postPassword(router, "https://s.example.com/s/locked", "secret")
+	assert.Equal(t, response.Code, http.StatusSeeOther)
+	assert.Equal(t, response.Result().Cookies()[0].Secure, true)
+
+	// Behind a TLS-terminating proxy the request is plain HTTP, the setting forces the flag
+	setting.Cfg.HTTP.SecureCookies = true
+	response = postPassword(router, "/s/locked", "secret")
+	assert.Equal(t, response.Code, http.StatusSeeOther)
+	assert.Equal(t, response.Result().Cookies()[0].Secure, true)
+}
+
+func TestUnlockCookieRejected(t *testing.T) {
+	router := useRouter(t)
+	passwordHash := passhash.Hash("secret")
+	insertLink(t, model.Link{ShortHash: "locked", URL: "https://example.com/secret", Password: passwordHash})
+
+	opens := func(cookie *http.Cookie) bool {
+		req := httptest.NewRequest(http.MethodGet, "/s/locked", nil)
+		req.AddCookie(cookie)
+		return visit(router, req).Header().Get("Location") == "https://example.com/secret"
+	}
+	expires := time.Now().Add(time.Minute).Unix()
+	assert.Equal(t, opens(unlockCookie(testSessionSecret, "locked", passwordHash, expires)), true)
+
+	// Only the server can sign, the cookie ends at its expiry and changing the password revokes it
+	assert.Equal(t, opens(unlockCookie("another-secret", "locked", passwordHash, expires)), false)
+	assert.Equal(t, opens(unlockCookie(testSessionSecret, "locked", passwordHash, time.Now().Add(-time.Second).Unix())), false)
+	assert.Equal(t, opens(unlockCookie(testSessionSecret, "locked", passhash.Hash("previous"), expires)), false)
+	assert.Equal(t, opens(&http.Cookie{Name: "lls_unlock_locked", Value: strconv.FormatInt(expires, 10)}), false)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"linkshortener/controller"
+	"linkshortener/lib/passhash"
+	"linkshortener/model"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+)
+
+var testVariants = []model.Variant{
+	{Name: "a", URL: "https://example.com/a", Weight: 1},
+	{Name: "b", URL: "https://example.com/b", Weight: 1},
+}
+
+func TestStickyVariant(t *testing.T) {
+	router := useRouter(t)
+	insertLink(t, model.Link{ShortHash: "ab", URL: "https://example.com/", Variants: testVariants, StickyVariant: true})
+
+	// The first visit draws a variant and remembers it for the link only
+	response := visit(router, httptest.NewRequest(http.MethodGet, "/s/ab", nil))
+	assert.Equal(t, response.Code, http.StatusTemporaryRedirect)
+	cookies := response.Result().Cookies()
+	assert.Equal(t, len(cookies), 1)
+	assert.Equal(t, cookies[0].Name, "lls_variant_ab")
+	assert.Equal(t, cookies[0].Path, "/s/ab")
+	assert.Equal(t, response.Header().Get("Location"), "https://example.com/"+cookies[0].Value)
+
+	// The remembered variant is served again without drawing a new one
+	for i := 0; i < 10; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/s/ab", nil)
+		req.AddCookie(&http.Cookie{Name: "lls_variant_ab", Value: "b"})
+		response = visit(router, req)
+		assert.Equal(t, response.Header().Get("Location"), "https://example.com/b")
+		assert.Equal(t, len(response.Result().Cookies()), 0)
+	}
+
+	// A variant that was removed from the link is replaced
+	req := httptest.NewRequest(http.MethodGet, "/s/ab", nil)
+	req.AddCookie(&http.Cookie{Name: "lls_variant_ab", Value: "removed"})
+	response = visit(router, req)
+	cookies = response.Result().Cookies()
+	assert.Equal(t, len(cookies), 1)
+	assert.NotEqual(t, cookies[0].Value, "removed")
+	assert.Equal(t, response.Header().Get("Location"), "https://example.com/"+cookies[0].Value)
+}
+
+func TestStatsVariants(t *testing.T) {
+	router := captchaRouter(t)
+	router.POST("/api/stats_link", controller.StatsLink)
+	passhash.InitPasshash("test-key")
+	insertLink(t, model.Link{ShortHash: "ab", URL: "https://example.com/", Token: passhash.HashToken("token"), Variants: testVariants})
+	for _, variant := range []string{"a", "b", "a", ""} {
+		insertAccess(t, model.LinkInfo{Hash: "ab", Variant: variant, Created: 1700000000})
+	}
+
+	// Clicks served by a targeting rule have no variant and are left out of the breakdown
+	response := postWithCaptcha(router, "/api/stats_link", fmt.Sprintf(`{"hash":"ab","captcha":%q,"token":"token","page":1,"size":10}`, testCaptcha))
+	assert.Equal(t, response.Code, http.StatusOK)
+	var result struct {
+		Data struct {
+			Total    int64 `json:"total"`
+			Variants []struct {
+				Name   string `json:"name"`
+				URL    string `json:"url"`
+				Weight int    `json:"weight"`
+				Clicks int64  `json:"clicks"`
+			} `json:"variants"`
+		} `json:"data"`
+	}
+	assert.Equal(t, json.Unmarshal(response.Body.Bytes(), &result), nil)
+	assert.Equal(t, result.Data.Total, int64(4))
+	assert.Equal(t, len(result.Data.Variants), 2)
+	assert.Equal(t, result.Data.Variants[0].Name, "a")
+	assert.Equal(t, result.Data.Variants[0].URL, "https://example.com/a")
+	assert.Equal(t, result.Data.Variants[0].Clicks, int64(2))
+	assert.Equal(t, result.Data.Variants[1].Name, "b")
+	assert.Equal(t, result.Data.Variants[1].Clicks, int64(1))
+}